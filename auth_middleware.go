@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/adamsma/webserver/internal/auth"
+)
+
+type identityKey struct{}
+
+// requireAuth only calls next for requests carrying a valid access token
+// granted scope. The token's claims are available to next via identity.
+func (cfg *apiConfig) requireAuth(scope auth.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+
+		claims, ok := cfg.authenticate(resp, req)
+		if !ok {
+			return
+		}
+
+		if !claims.HasScope(scope) {
+			resp.Header().Set(
+				"WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", scope="%s"`, scope),
+			)
+			respondWithError(
+				resp,
+				http.StatusForbidden,
+				fmt.Sprintf("Token is missing the %s scope", scope),
+				nil,
+			)
+			return
+		}
+
+		next(resp, req.WithContext(context.WithValue(req.Context(), identityKey{}, claims)))
+	}
+}
+
+// requireAdmin guards operator endpoints with ADMIN_API_KEY, presented as
+// "Authorization: ApiKey <key>". They're all refused when no key is set.
+func (cfg *apiConfig) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+
+		apiKey, err := auth.GetAPIKey(req.Header)
+		if err != nil || !auth.ValidAPIKey(apiKey, cfg.adminKey) {
+			respondWithError(
+				resp,
+				http.StatusUnauthorized,
+				"Invalid credentials",
+				err,
+			)
+			return
+		}
+
+		next(resp, req)
+	}
+}
+
+// optionalAuth lets anonymous requests through but still rejects a token
+// that's present and invalid, so clients find out their session expired
+// rather than silently seeing a logged out view
+func (cfg *apiConfig) optionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+
+		if req.Header.Get("Authorization") == "" {
+			next(resp, req)
+			return
+		}
+
+		claims, ok := cfg.authenticate(resp, req)
+		if !ok {
+			return
+		}
+
+		next(resp, req.WithContext(context.WithValue(req.Context(), identityKey{}, claims)))
+	}
+}
+
+func (cfg *apiConfig) authenticate(resp http.ResponseWriter, req *http.Request) (auth.Claims, bool) {
+
+	authToken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		// no error code when credentials are absent (RFC 6750 section 3.1)
+		resp.Header().Set("WWW-Authenticate", `Bearer realm="chirpy"`)
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
+			"Invalid credentials",
+			err,
+		)
+		return auth.Claims{}, false
+	}
+
+	claims, err := auth.ValidateJWT(authToken, cfg.keys)
+	if err != nil {
+		resp.Header().Set(
+			"WWW-Authenticate",
+			`Bearer realm="chirpy", error="invalid_token", error_description="The access token is invalid or expired"`,
+		)
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
+			"Invalid credentials",
+			err,
+		)
+		return auth.Claims{}, false
+	}
+
+	return claims, true
+}
+
+// identity returns the caller's claims for handlers behind requireAuth or
+// optionalAuth; ok is false for anonymous requests
+func identity(req *http.Request) (claims auth.Claims, ok bool) {
+	claims, ok = req.Context().Value(identityKey{}).(auth.Claims)
+	return claims, ok
+}
+
+// requiredIdentity is for handlers registered with requireAuth, where a
+// missing identity means the route was wired up without it
+func requiredIdentity(req *http.Request) auth.Claims {
+
+	claims, ok := identity(req)
+	if !ok {
+		panic(fmt.Sprintf("%s %s handled without requireAuth", req.Method, req.URL.Path))
+	}
+
+	return claims
+}
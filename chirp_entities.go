@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/entities"
+	"github.com/adamsma/webserver/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+type ChirpEntities struct {
+	Hashtags []string       `json:"hashtags"`
+	Mentions []ChirpMention `json:"mentions"`
+}
+
+// ChirpMention names a mentioned user by id only. The address written in the
+// body is never echoed back, so a response can't be used to confirm which
+// addresses belong to accounts.
+type ChirpMention struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type TrendingHashtag struct {
+	Tag   string  `json:"tag"`
+	Uses  int64   `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+// newChirpEntities parses the hashtags out of a chirp body. Mentions need a
+// database lookup and are filled in by attachMentions.
+func newChirpEntities(body string) ChirpEntities {
+	return ChirpEntities{
+		Hashtags: entities.Parse(body).Hashtags,
+		Mentions: []ChirpMention{},
+	}
+}
+
+// saveChirpEntities indexes the hashtags and mentions in a chirp. When replace
+// is set any entities from a previous version of the chirp are removed first.
+// q should be the transaction that wrote the chirp.
+func saveChirpEntities(ctx context.Context, q *database.Queries, chirp Chirp, replace bool) error {
+
+	parsed := entities.Parse(chirp.Body)
+
+	if replace {
+		if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+			return fmt.Errorf("unable to clear hashtags: %w", err)
+		}
+		if err := q.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+			return fmt.Errorf("unable to clear mentions: %w", err)
+		}
+	}
+
+	if len(parsed.Hashtags) > 0 {
+		err := q.AddChirpHashtags(ctx, database.AddChirpHashtagsParams{
+			Tags:      parsed.Hashtags,
+			ChirpID:   chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to save hashtags: %w", err)
+		}
+	}
+
+	if len(parsed.Mentions) > 0 {
+		err := q.AddChirpMentions(ctx, database.AddChirpMentionsParams{
+			ChirpID: chirp.ID,
+			Emails:  parsed.Mentions,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to save mentions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// attachMentions fills in the users mentioned by each chirp
+func (cfg *apiConfig) attachMentions(ctx context.Context, chirps []*Chirp) error {
+
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	mentions, err := cfg.db.GetChirpMentions(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+
+	byChirp := map[uuid.UUID][]database.ChirpMention{}
+	for _, mention := range mentions {
+		byChirp[mention.ChirpID] = append(byChirp[mention.ChirpID], mention)
+	}
+
+	// report mentions in the order they are written
+	for _, chirp := range chirps {
+		resolved := map[string]ChirpMention{}
+		for _, mention := range byChirp[chirp.ID] {
+			resolved[mention.Mention] = ChirpMention{UserID: mention.UserID}
+		}
+
+		for _, email := range entities.Parse(chirp.Body).Mentions {
+			if mention, ok := resolved[email]; ok {
+				chirp.Entities.Mentions = append(chirp.Entities.Mentions, mention)
+			}
+		}
+	}
+
+	return nil
+}
+
+// decorateChirps adds the per request details to chirps about to be returned:
+// mentioned users and whether the caller has liked them
+func (cfg *apiConfig) decorateChirps(req *http.Request, chirps ...*Chirp) error {
+
+	if err := cfg.attachMentions(req.Context(), chirps); err != nil {
+		return err
+	}
+
+	return cfg.markLikedChirps(req.Context(), optionalUserID(req), chirps)
+}
+
+func chirpRefs(chirps []Chirp) []*Chirp {
+
+	refs := make([]*Chirp, 0, len(chirps))
+	for i := range chirps {
+		refs = append(refs, &chirps[i])
+	}
+
+	return refs
+}
+
+func (cfg *apiConfig) handleGetHashtagChirps(resp http.ResponseWriter, req *http.Request) {
+
+	tag := entities.NormalizeHashtag(req.PathValue("tag"))
+	if tag == "" {
+		respondWithError(resp, http.StatusBadRequest, "Invalid hashtag", nil)
+		return
+	}
+
+	cfg.respondWithChirpPage(resp, req, true, func(
+		ctx context.Context, cursor pagination.Cursor, ascending bool, limit int32,
+	) ([]Chirp, error) {
+		if ascending {
+			return databaseChirpsToChirps(cfg.db.GetHashtagChirpsAfter(ctx, database.GetHashtagChirpsAfterParams{
+				Tag:             tag,
+				CursorCreatedAt: cursor.CreatedAt,
+				CursorID:        cursor.ID,
+				PageLimit:       limit,
+			}))
+		}
+
+		return databaseChirpsToChirps(cfg.db.GetHashtagChirpsBefore(ctx, database.GetHashtagChirpsBeforeParams{
+			Tag:             tag,
+			CursorCreatedAt: cursor.CreatedAt,
+			CursorID:        cursor.ID,
+			PageLimit:       limit,
+		}))
+	})
+
+}
+
+// handleGetTrending ranks hashtags used within a sliding window (24h unless a
+// window such as "6h" is requested). Each use decays in weight with a half
+// life of a quarter of the window, so recent bursts outrank steady usage.
+func (cfg *apiConfig) handleGetTrending(resp http.ResponseWriter, req *http.Request) {
+
+	query := req.URL.Query()
+
+	window := defaultTrendingWindow
+	if raw := query.Get("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			respondWithError(
+				resp,
+				http.StatusBadRequest,
+				fmt.Sprintf("Window must be a duration up to %s", maxTrendingWindow),
+				err,
+			)
+			return
+		}
+
+		window = parsed
+	}
+
+	limit := defaultTrendingLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := pagination.ParseLimit(raw)
+		if err != nil {
+			respondWithError(resp, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+
+		limit = parsed
+	}
+
+	trending, err := cfg.db.GetTrendingHashtags(req.Context(), database.GetTrendingHashtagsParams{
+		HalfLifeSeconds: (window / 4).Seconds(),
+		WindowSeconds:   window.Seconds(),
+		PageLimit:       int32(limit),
+	})
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve trending hashtags",
+			err,
+		)
+
+		return
+	}
+
+	returnTrending := []TrendingHashtag{}
+	for _, hashtag := range trending {
+		returnTrending = append(returnTrending, TrendingHashtag{
+			Tag:   hashtag.Tag,
+			Uses:  hashtag.Uses,
+			Score: hashtag.Score,
+		})
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnTrending)
+
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adamsma/webserver/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+// chirpPageFetcher loads up to limit chirps strictly after (ascending) or
+// before (descending) the cursor position, in that scan order
+type chirpPageFetcher func(
+	ctx context.Context, cursor pagination.Cursor, ascending bool, limit int32,
+) ([]Chirp, error)
+
+// sorts after every stored chirp; paired with uuid.Max it is the starting
+// position of a descending listing
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// respondWithChirpPage serves one page of a cursor paginated chirp listing.
+// It understands the limit, cursor and sort (asc or desc) query parameters and
+// advertises neighbouring pages with a Link header.
+func (cfg *apiConfig) respondWithChirpPage(
+	resp http.ResponseWriter,
+	req *http.Request,
+	defaultDescending bool,
+	fetch chirpPageFetcher,
+) {
+
+	query := req.URL.Query()
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	descending := defaultDescending
+	switch query.Get("sort") {
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	}
+
+	cursor := pagination.Cursor{Direction: pagination.DirectionNext}
+	hasCursor := query.Get("cursor") != ""
+	if hasCursor {
+		cursor, err = pagination.DecodeCursor(query.Get("cursor"))
+		if err != nil {
+			respondWithError(resp, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+	} else if descending {
+		cursor.CreatedAt = endOfTime
+		cursor.ID = uuid.Max
+	}
+
+	// walking backwards through a listing scans the table in the opposite
+	// order, so the page has to be flipped before it is returned
+	ascending := descending == (cursor.Direction == pagination.DirectionPrev)
+
+	// fetch one extra row to find out whether another page exists
+	chirps, err := fetch(req.Context(), cursor, ascending, int32(limit+1))
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve chirps",
+			err,
+		)
+
+		return
+	}
+
+	hasMore := len(chirps) > limit
+	if hasMore {
+		chirps = chirps[:limit]
+	}
+
+	if cursor.Direction == pagination.DirectionPrev {
+		slices.Reverse(chirps)
+	}
+
+	err = cfg.decorateChirps(req, chirpRefs(chirps)...)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve chirps",
+			err,
+		)
+
+		return
+	}
+
+	if len(chirps) > 0 {
+		first, last := chirps[0], chirps[len(chirps)-1]
+
+		var links []string
+		if (cursor.Direction == pagination.DirectionNext && hasMore) ||
+			cursor.Direction == pagination.DirectionPrev {
+			next := pagination.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.ID,
+				Direction: pagination.DirectionNext,
+			}
+			links = append(links, pageLink(req, next, "next"))
+		}
+
+		if (cursor.Direction == pagination.DirectionPrev && hasMore) ||
+			(cursor.Direction == pagination.DirectionNext && hasCursor) {
+			prev := pagination.Cursor{
+				CreatedAt: first.CreatedAt,
+				ID:        first.ID,
+				Direction: pagination.DirectionPrev,
+			}
+			links = append(links, pageLink(req, prev, "prev"))
+		}
+
+		if len(links) > 0 {
+			resp.Header().Set("Link", strings.Join(links, ", "))
+		}
+	}
+
+	respondWithJSON(resp, http.StatusOK, chirps)
+
+}
+
+// pageLink builds an RFC 8288 link to the same listing positioned at cursor
+func pageLink(req *http.Request, cursor pagination.Cursor, rel string) string {
+
+	query := req.URL.Query()
+	query.Set("cursor", cursor.Encode())
+
+	target := url.URL{Path: req.URL.Path, RawQuery: query.Encode()}
+
+	return fmt.Sprintf("<%s>; rel=\"%s\"", target.String(), rel)
+}
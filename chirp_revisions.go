@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) handleGetChirpRevisions(resp http.ResponseWriter, req *http.Request) {
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid chirp ID",
+			nil,
+		)
+
+		return
+	}
+
+	_, err = cfg.db.GetChirpByID(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"Chirp not found",
+			nil,
+		)
+
+		return
+	}
+
+	revisions, err := cfg.db.GetChirpRevisions(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve chirp revisions",
+			err,
+		)
+
+		return
+	}
+
+	returnRevisions := []ChirpRevision{}
+	for _, revision := range revisions {
+		returnRevisions = append(returnRevisions, ChirpRevision{
+			ID:         revision.ID,
+			ChirpID:    revision.ChirpID,
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnRevisions)
+
+}
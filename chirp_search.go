@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/pagination"
+	"github.com/adamsma/webserver/internal/search"
+
+	"github.com/google/uuid"
+)
+
+type ChirpSearchResult struct {
+	Chirp
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+func (cfg *apiConfig) handleSearchChirps(resp http.ResponseWriter, req *http.Request) {
+
+	query := req.URL.Query()
+
+	tsQuery, err := search.ToTSQuery(query.Get("q"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid search query", err)
+		return
+	}
+
+	var authorID uuid.NullUUID
+	if author := query.Get("author_id"); author != "" {
+		id, err := uuid.Parse(author)
+		if err != nil {
+			respondWithError(resp, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	// results are ordered by relevance unless a date order is requested
+	sort := query.Get("sort")
+	if sort != "asc" && sort != "desc" {
+		sort = ""
+	}
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	highlighter, err := search.NewHighlighter()
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to search chirps",
+			err,
+		)
+
+		return
+	}
+
+	results, err := cfg.db.SearchChirps(req.Context(), database.SearchChirpsParams{
+		HeadlineOptions: highlighter.HeadlineOptions(),
+		Query:           tsQuery,
+		AuthorID:        authorID,
+		Sort:            sort,
+		PageLimit:       int32(limit),
+	})
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to search chirps",
+			err,
+		)
+
+		return
+	}
+
+	returnResults := []ChirpSearchResult{}
+	for _, result := range results {
+		returnResults = append(returnResults, ChirpSearchResult{
+			Chirp: Chirp{
+				ID:        result.ID,
+				CreatedAt: result.CreatedAt,
+				UpdatedAt: result.UpdatedAt,
+				Body:      result.Body,
+				UserID:    result.UserID,
+				Edited:    result.EditedAt.Valid,
+				InReplyTo: result.ParentID,
+				Likes:     result.LikeCount,
+				Entities:  newChirpEntities(result.Body),
+			},
+			Rank:    result.Rank,
+			Snippet: highlighter.EscapeSnippet(result.Snippet),
+		})
+	}
+
+	refs := make([]*Chirp, 0, len(returnResults))
+	for i := range returnResults {
+		refs = append(refs, &returnResults[i].Chirp)
+	}
+
+	err = cfg.decorateChirps(req, refs...)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to search chirps",
+			err,
+		)
+
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnResults)
+
+}
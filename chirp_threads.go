@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/adamsma/webserver/internal/database"
+
+	"github.com/google/uuid"
+)
+
+type ThreadChirp struct {
+	Chirp
+	Depth   int32         `json:"depth"`
+	Deleted bool          `json:"deleted"`
+	Replies []ThreadChirp `json:"replies,omitempty"`
+}
+
+func (cfg *apiConfig) handleGetChirpThread(resp http.ResponseWriter, req *http.Request) {
+
+	type response struct {
+		Ancestors []ThreadChirp `json:"ancestors"`
+		Chirp     ThreadChirp   `json:"chirp"`
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid chirp ID",
+			nil,
+		)
+
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpByID(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"Chirp not found",
+			nil,
+		)
+
+		return
+	}
+
+	ancestors, err := cfg.db.GetChirpAncestors(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve thread",
+			err,
+		)
+
+		return
+	}
+
+	descendants, err := cfg.db.GetChirpDescendants(
+		req.Context(),
+		uuid.NullUUID{UUID: chirpID, Valid: true},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve thread",
+			err,
+		)
+
+		return
+	}
+
+	// depths are reported relative to the root of the conversation; the
+	// queries count outwards from the requested chirp
+	chirpDepth := int32(len(ancestors))
+
+	returnAncestors := []ThreadChirp{}
+	for _, ancestor := range ancestors {
+		returnAncestors = append(
+			returnAncestors,
+			threadRowToThreadChirp(database.GetChirpDescendantsRow(ancestor), chirpDepth-ancestor.Depth),
+		)
+	}
+
+	replies := map[uuid.UUID][]database.GetChirpDescendantsRow{}
+	for _, descendant := range descendants {
+		replies[descendant.ParentID.UUID] = append(replies[descendant.ParentID.UUID], descendant)
+	}
+
+	var buildReplies func(parentID uuid.UUID) []ThreadChirp
+	buildReplies = func(parentID uuid.UUID) []ThreadChirp {
+		var tree []ThreadChirp
+		for _, reply := range replies[parentID] {
+			node := threadRowToThreadChirp(reply, chirpDepth+reply.Depth)
+			node.Replies = buildReplies(reply.ID)
+			tree = append(tree, node)
+		}
+
+		return tree
+	}
+
+	thread := response{
+		Ancestors: returnAncestors,
+		Chirp: ThreadChirp{
+			Chirp:   databaseChirpToChirp(chirp),
+			Depth:   chirpDepth,
+			Replies: buildReplies(chirp.ID),
+		},
+	}
+
+	var refs []*Chirp
+	var collect func(nodes []ThreadChirp)
+	collect = func(nodes []ThreadChirp) {
+		for i := range nodes {
+			refs = append(refs, &nodes[i].Chirp)
+			collect(nodes[i].Replies)
+		}
+	}
+	collect(thread.Ancestors)
+	refs = append(refs, &thread.Chirp.Chirp)
+	collect(thread.Chirp.Replies)
+
+	err = cfg.decorateChirps(req, refs...)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve thread",
+			err,
+		)
+
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, thread)
+
+}
+
+func threadRowToThreadChirp(row database.GetChirpDescendantsRow, depth int32) ThreadChirp {
+	return ThreadChirp{
+		Chirp: Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			Edited:    row.EditedAt.Valid,
+			InReplyTo: row.ParentID,
+			Likes:     row.LikeCount,
+			Entities:  newChirpEntities(row.Body),
+		},
+		Depth:   depth,
+		Deleted: row.DeletedAt.Valid,
+	}
+}
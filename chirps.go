@@ -2,186 +2,387 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"slices"
-	"strings"
 	"time"
 
-	"github.com/adamsma/webserver/internal/auth"
 	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/moderation"
+	"github.com/adamsma/webserver/internal/pagination"
 
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Edited    bool          `json:"edited"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	Likes     int32         `json:"likes"`
+	LikedByMe bool          `json:"liked_by_me"`
+	Entities  ChirpEntities `json:"entities"`
+}
+
+// chirpColumns is the row every chirp query returns: the chirps table less
+// its search vector, which only search reads
+type chirpColumns = struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+// chirpRow is met by the row type generated for each chirp query
+type chirpRow interface {
+	~chirpColumns
+}
+
+func databaseChirpToChirp[T chirpRow](row T) Chirp {
+
+	chirp := chirpColumns(row)
+
+	return Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		Edited:    chirp.EditedAt.Valid,
+		InReplyTo: chirp.ParentID,
+		Likes:     chirp.LikeCount,
+		Entities:  newChirpEntities(chirp.Body),
+	}
+}
+
+// databaseChirpsToChirps converts the rows of a chirp listing query
+func databaseChirpsToChirps[T chirpRow](rows []T, err error) ([]Chirp, error) {
+
+	if err != nil {
+		return nil, err
+	}
+
+	chirps := make([]Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, databaseChirpToChirp(row))
+	}
+
+	return chirps, nil
 }
 
 func (cfg *apiConfig) handleNewChirp(resp http.ResponseWriter, req *http.Request) {
 
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string        `json:"body"`
+		InReplyTo uuid.NullUUID `json:"in_reply_to"`
+		// a time in the future schedules the chirp instead of posting it now
+		PublishAt *time.Time `json:"publish_at"`
 	}
 
 	type response struct {
 		Chirp
+		Moderation []moderation.Match `json:"moderation,omitempty"`
 	}
 
-	authToken, err := auth.GetBearerToken(req.Header)
+	userID := requiredIdentity(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusUnauthorized,
-			"Invalid credentials",
-			err,
+			http.StatusInternalServerError,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
 		)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, cfg.secret)
+	_, perks, err := cfg.perksFor(req.Context(), userID)
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusUnauthorized,
-			"Invalid credentials",
+			http.StatusInternalServerError,
+			"Unable to create chirp",
 			err,
 		)
 		return
 	}
 
-	decoder := json.NewDecoder(req.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
+	if !cfg.checkChirpRate(resp, req, userID, perks) {
+		return
+	}
+
+	scheduling := params.PublishAt != nil && params.PublishAt.After(time.Now())
+	if scheduling && !perks.ScheduleChirps {
 		respondWithError(
 			resp,
-			http.StatusInternalServerError,
-			"Couldn't decode parameters",
-			fmt.Errorf("error decoding parameters: %s", err),
+			http.StatusForbidden,
+			"Scheduling chirps requires Chirpy Red",
+			nil,
 		)
 		return
 	}
 
-	cleanedBody, err := validateChirp(resp, params.Body)
+	moderated, err := cfg.validateChirp(resp, params.Body, perks.MaxChirpLength)
 	if err != nil {
 		return
 	}
 
-	chirp, err := cfg.db.CreateChirp(
-		req.Context(),
-		database.CreateChirpParams{Body: cleanedBody, UserID: userID},
-	)
+	if params.InReplyTo.Valid {
+		_, err = cfg.db.GetChirpByID(req.Context(), params.InReplyTo.UUID)
+		if err != nil {
+			respondWithError(
+				resp,
+				http.StatusBadRequest,
+				"Chirp being replied to not found",
+				err,
+			)
+
+			return
+		}
+	}
+
+	if scheduling {
+		scheduled, err := cfg.db.CreateScheduledChirp(
+			req.Context(),
+			database.CreateScheduledChirpParams{
+				UserID:    userID,
+				Body:      moderated.Body,
+				ParentID:  params.InReplyTo,
+				PublishAt: params.PublishAt.UTC(),
+			},
+		)
+		if err != nil {
+			respondWithError(
+				resp,
+				http.StatusInternalServerError,
+				"Unable to schedule chirp",
+				err,
+			)
+
+			return
+		}
+
+		respondWithJSON(resp, http.StatusAccepted, databaseScheduledChirpToScheduledChirp(scheduled))
+		return
+	}
+
+	var returnChirp Chirp
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		chirp, err := q.CreateChirp(
+			req.Context(),
+			database.CreateChirpParams{
+				Body:     moderated.Body,
+				UserID:   userID,
+				ParentID: params.InReplyTo,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		returnChirp = databaseChirpToChirp(chirp)
+		return saveChirpEntities(req.Context(), q, returnChirp, false)
+	})
 	if err != nil {
 		respondWithError(
 			resp,
 			http.StatusInternalServerError,
 			"Unable to create chirp",
-			nil,
+			err,
 		)
 
 		return
 	}
 
+	cfg.recordModerationFlags(req.Context(), returnChirp.ID, moderated)
+
+	// the chirp is saved by now, so it's returned even if the mentions can't
+	// be looked up
+	if err := cfg.decorateChirps(req, &returnChirp); err != nil {
+		log.Printf("unable to decorate new chirp %s: %s", returnChirp.ID, err)
+	}
+
+	cfg.publishChirpEvent(streamEventChirpCreated, returnChirp.UserID, returnChirp)
+
 	respondWithJSON(resp, http.StatusCreated, response{
-		Chirp: Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		},
+		Chirp:      returnChirp,
+		Moderation: moderated.Matches,
 	})
 
 }
 
-func validateChirp(resp http.ResponseWriter, body string) (string, error) {
+// validateChirp runs a chirp body through moderation, responding with an
+// error when it can't be posted. maxLength comes from the author's perks.
+func (cfg *apiConfig) validateChirp(resp http.ResponseWriter, body string, maxLength int) (moderation.Result, error) {
+
+	type rejection struct {
+		Error      string             `json:"error"`
+		Moderation []moderation.Match `json:"moderation"`
+	}
+
+	result := cfg.moderator.Moderate(body)
+
+	if result.Length > maxLength {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			fmt.Sprintf("Chirp is too long, the limit is %d characters", maxLength),
+			nil,
+		)
+		return moderation.Result{}, fmt.Errorf("chirp too long: %d characters", result.Length)
+	}
+
+	if result.Rejected {
+		respondWithJSON(resp, http.StatusBadRequest, rejection{
+			Error:      "Chirp contains prohibited content",
+			Moderation: result.Matches,
+		})
+		return moderation.Result{}, fmt.Errorf("chirp rejected by moderation: %v", result.Matches)
+	}
+
+	return result, nil
+
+}
+
+// recordModerationFlags queues a chirp for review when moderation flagged it
+func (cfg *apiConfig) recordModerationFlags(ctx context.Context, chirpID uuid.UUID, result moderation.Result) {
 
-	if len(body) > 140 {
-		respondWithError(resp, http.StatusBadRequest, "Chirp is too long", nil)
-		return "", fmt.Errorf("chirp too long: %d characters", len(body))
+	if !result.Flagged {
+		return
 	}
 
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		switch strings.ToLower(word) {
-		case "kerfuffle", "sharbert", "fornax":
-			words[i] = "****"
-		default:
-			//do nothing
+	var terms []string
+	for _, match := range result.Matches {
+		if match.Action == moderation.ActionFlag {
+			terms = append(terms, match.Term)
 		}
 	}
 
-	return strings.Join(words, " "), nil
+	err := cfg.db.FlagChirp(ctx, database.FlagChirpParams{ChirpID: chirpID, Terms: terms})
+	if err != nil {
+		log.Printf("unable to flag chirp %s for review: %s", chirpID, err)
+	}
 
 }
 
 func (cfg *apiConfig) handleGetChirps(resp http.ResponseWriter, req *http.Request) {
 
 	author := req.URL.Query().Get("author_id")
-	var fx func(ctx context.Context) ([]database.Chirp, error)
 	if author == "" {
-		fx = cfg.db.GetChirps
-	} else {
+		cfg.respondWithChirpPage(resp, req, false, func(
+			ctx context.Context, cursor pagination.Cursor, ascending bool, limit int32,
+		) ([]Chirp, error) {
+			if ascending {
+				return databaseChirpsToChirps(cfg.db.GetChirpsAfter(ctx, database.GetChirpsAfterParams{
+					CursorCreatedAt: cursor.CreatedAt,
+					CursorID:        cursor.ID,
+					PageLimit:       limit,
+				}))
+			}
+
+			return databaseChirpsToChirps(cfg.db.GetChirpsBefore(ctx, database.GetChirpsBeforeParams{
+				CursorCreatedAt: cursor.CreatedAt,
+				CursorID:        cursor.ID,
+				PageLimit:       limit,
+			}))
+		})
 
-		authorID, err := uuid.Parse(author)
-		if err != nil {
-			respondWithError(resp, http.StatusBadRequest, "Invalid author ID", err)
-			return
-		}
+		return
+	}
 
-		fx = func(ctx context.Context) ([]database.Chirp, error) {
-			return cfg.db.GetChirpsByAuthor(ctx, authorID)
-		}
+	authorID, err := uuid.Parse(author)
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid author ID", err)
+		return
 	}
 
-	chirps, err := fx(req.Context())
+	cfg.respondWithChirpPage(resp, req, false, func(
+		ctx context.Context, cursor pagination.Cursor, ascending bool, limit int32,
+	) ([]Chirp, error) {
+		if ascending {
+			return databaseChirpsToChirps(cfg.db.GetChirpsByAuthorAfter(ctx, database.GetChirpsByAuthorAfterParams{
+				UserID:          authorID,
+				CursorCreatedAt: cursor.CreatedAt,
+				CursorID:        cursor.ID,
+				PageLimit:       limit,
+			}))
+		}
+
+		return databaseChirpsToChirps(cfg.db.GetChirpsByAuthorBefore(ctx, database.GetChirpsByAuthorBeforeParams{
+			UserID:          authorID,
+			CursorCreatedAt: cursor.CreatedAt,
+			CursorID:        cursor.ID,
+			PageLimit:       limit,
+		}))
+	})
+
+}
+
+func (cfg *apiConfig) handleGetChirpByID(resp http.ResponseWriter, req *http.Request) {
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusInternalServerError,
-			"Unable to retrieve chirps",
+			http.StatusBadRequest,
+			"Invalid chirpID",
 			nil,
 		)
 
 		return
 	}
 
-	var returnChirps []Chirp
-	for _, chirp := range chirps {
-		returnChirps = append(returnChirps, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
-	}
+	chirp, err := cfg.db.GetChirpByID(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"Chirp not found",
+			nil,
+		)
 
-	sort := req.URL.Query().Get("sort")
-	if sort == "desc" {
-		slices.SortFunc(returnChirps, func(i, j Chirp) int {
-			return j.CreatedAt.Compare(i.CreatedAt)
-		})
+		return
 	}
 
-	respondWithJSON(resp, http.StatusOK, returnChirps)
+	returnChirp := databaseChirpToChirp(chirp)
+	err = cfg.decorateChirps(req, &returnChirp)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve chirp",
+			err,
+		)
+
+		return
+	}
 
+	respondWithJSON(resp, http.StatusOK, returnChirp)
 }
 
-func (cfg *apiConfig) handleGetChirpByID(resp http.ResponseWriter, req *http.Request) {
+func (cfg *apiConfig) handleDeleteChirp(resp http.ResponseWriter, req *http.Request) {
+
+	userID := requiredIdentity(req).UserID
 
 	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil {
 		respondWithError(
 			resp,
 			http.StatusBadRequest,
-			"Invalid chirpID",
+			"Invalid chirp ID",
 			nil,
 		)
 
@@ -200,39 +401,72 @@ func (cfg *apiConfig) handleGetChirpByID(resp http.ResponseWriter, req *http.Req
 		return
 	}
 
-	respondWithJSON(resp, http.StatusOK, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
-}
+	if chirp.UserID != userID {
+		respondWithError(
+			resp,
+			http.StatusForbidden,
+			"Chirp can only be deleted by author",
+			nil,
+		)
 
-func (cfg *apiConfig) handleDeleteChirp(resp http.ResponseWriter, req *http.Request) {
+		return
+	}
 
-	authToken, err := auth.GetBearerToken(req.Header)
+	// replies keep their place in the thread, so a chirp that has any is
+	// reduced to a tombstone instead of being removed
+	hasReplies, err := cfg.db.ChirpHasReplies(
+		req.Context(),
+		uuid.NullUUID{UUID: chirpID, Valid: true},
+	)
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusUnauthorized,
-			"Invalid credentials",
+			http.StatusInternalServerError,
+			"Unable to delete chirp",
 			err,
 		)
+
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, cfg.secret)
+	if hasReplies {
+		_, err = cfg.db.TombstoneChirp(req.Context(), chirpID)
+	} else {
+		err = cfg.db.DeleteChirp(req.Context(), chirpID)
+	}
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusUnauthorized,
-			"Invalid credentials",
-			err,
+			http.StatusInternalServerError,
+			"Unable to delete chirp",
+			nil,
 		)
+
 		return
 	}
 
+	cfg.publishChirpEvent(streamEventChirpDeleted, chirp.UserID, struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}{ID: chirp.ID, UserID: chirp.UserID})
+
+	resp.WriteHeader(http.StatusNoContent)
+
+}
+
+func (cfg *apiConfig) handleUpdateChirp(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	type response struct {
+		Chirp
+		Moderation []moderation.Match `json:"moderation,omitempty"`
+	}
+
+	userID := requiredIdentity(req).UserID
+
 	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil {
 		respondWithError(
@@ -245,6 +479,19 @@ func (cfg *apiConfig) handleDeleteChirp(resp http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
 	chirp, err := cfg.db.GetChirpByID(req.Context(), chirpID)
 	if err != nil {
 		respondWithError(
@@ -261,25 +508,74 @@ func (cfg *apiConfig) handleDeleteChirp(resp http.ResponseWriter, req *http.Requ
 		respondWithError(
 			resp,
 			http.StatusForbidden,
-			"Chirp can only be deleted by author",
+			"Chirp can only be edited by author",
 			nil,
 		)
 
 		return
 	}
 
-	err = cfg.db.DeleteChirp(req.Context(), chirpID)
+	_, perks, err := cfg.perksFor(req.Context(), userID)
 	if err != nil {
 		respondWithError(
 			resp,
 			http.StatusInternalServerError,
-			"Unable to delete chirp",
+			"Unable to update chirp",
+			err,
+		)
+
+		return
+	}
+
+	if !perks.EditChirps {
+		respondWithError(
+			resp,
+			http.StatusForbidden,
+			"Editing chirps requires Chirpy Red",
 			nil,
 		)
 
 		return
 	}
 
-	resp.WriteHeader(http.StatusNoContent)
+	moderated, err := cfg.validateChirp(resp, params.Body, perks.MaxChirpLength)
+	if err != nil {
+		return
+	}
+
+	var returnChirp Chirp
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		updated, err := q.UpdateChirpBody(
+			req.Context(),
+			database.UpdateChirpBodyParams{ID: chirpID, Body: moderated.Body},
+		)
+		if err != nil {
+			return err
+		}
+
+		returnChirp = databaseChirpToChirp(updated)
+		return saveChirpEntities(req.Context(), q, returnChirp, true)
+	})
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to update chirp",
+			err,
+		)
+
+		return
+	}
+
+	cfg.recordModerationFlags(req.Context(), returnChirp.ID, moderated)
+
+	if err := cfg.decorateChirps(req, &returnChirp); err != nil {
+		log.Printf("unable to decorate updated chirp %s: %s", returnChirp.ID, err)
+	}
+
+	respondWithJSON(resp, http.StatusOK, response{
+		Chirp:      returnChirp,
+		Moderation: moderated.Matches,
+	})
 
 }
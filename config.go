@@ -1,35 +1,109 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
-	"sync/atomic"
+	"time"
 
+	"github.com/adamsma/webserver/internal/auth"
 	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/email"
+	"github.com/adamsma/webserver/internal/entitlements"
+	"github.com/adamsma/webserver/internal/metrics"
+	"github.com/adamsma/webserver/internal/moderation"
+	"github.com/adamsma/webserver/internal/stream"
+	"github.com/adamsma/webserver/internal/throttle"
+	"github.com/adamsma/webserver/internal/webhooks"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	env            string
-	secret         string
-	paymentKey     string
+	db         *database.Queries
+	conn       *sql.DB
+	env        string
+	keys       *auth.KeyRing
+	paymentKey string
+	moderator  *moderation.Moderator
+	stream     *stream.Broker
+	metrics    *metrics.Metrics
+	lifetimes  tokenLifetimes
+	mailer     *email.Queue
+	baseURL    string
+	adminKey   string
+
+	entitlements entitlements.Matrix
+
+	// failed logins, tracked separately per account and per client address
+	accountLockout *throttle.Limiter
+	addrLockout    *throttle.Limiter
+
+	// nil when Polka webhooks aren't signed
+	paymentWebhooks *auth.WebhookVerifier
+
+	webhookSender *webhooks.Sender
 }
 
-func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
-		cfg.fileserverHits.Add(1)
-		next.ServeHTTP(resp, req)
-	})
+// inTx runs fn against a single transaction, committing only if it succeeds.
+// Queries are instrumented the same way as cfg.db.
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(*database.Queries) error) error {
+
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(database.New(cfg.metrics.InstrumentDB(tx))); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+type tokenLifetimes struct {
+	access            time.Duration
+	maxAccess         time.Duration
+	refresh           time.Duration
+	passwordReset     time.Duration
+	emailVerification time.Duration
+}
+
+// accessExpiry uses the client's requested lifetime when given, clamped to
+// the configured maximum
+func (l tokenLifetimes) accessExpiry(requestedSeconds int) time.Time {
+
+	lifetime := l.access
+	if requestedSeconds > 0 {
+		lifetime = min(time.Duration(requestedSeconds)*time.Second, l.maxAccess)
+	}
+
+	return time.Now().UTC().Add(lifetime).Truncate(time.Second)
+}
+
+// route the fileserver is mounted on; visits are counted by the metrics
+// middleware under this pattern
+const fileserverRoute = "/app/"
+
 func (cfg *apiConfig) handlerHits(resp http.ResponseWriter, req *http.Request) {
 
+	hits, err := cfg.metrics.RequestCount(fileserverRoute)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to gather metrics",
+			fmt.Errorf("error in gathering metrics: %s", err),
+		)
+
+		return
+	}
+
 	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
 	resp.WriteHeader(http.StatusOK)
 
 	hitHTML := "<html><body><h1>Welcome, Chirpy Admin</h1>"
-	hitHTML += fmt.Sprintf("<p>Chirpy has been visited %d times!</p>", cfg.fileserverHits.Load())
+	hitHTML += fmt.Sprintf("<p>Chirpy has been visited %d times!</p>", int(hits))
 	hitHTML += "</body></html>"
 	resp.Write([]byte(hitHTML))
 
@@ -59,7 +133,7 @@ func (cfg *apiConfig) handlerReset(resp http.ResponseWriter, req *http.Request)
 	}
 
 	// reset hit counter
-	cfg.fileserverHits.Store(0)
+	cfg.metrics.ResetRequests()
 
 	resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	resp.WriteHeader(http.StatusOK)
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/adamsma/webserver/internal/auth"
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/email"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Postgres error raised when another account already has the address
+const uniqueViolation = "23505"
+
+// sendEmailVerification mails addr a link proving the user owns it. The
+// token records the address so confirming it can't verify a different one.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, addr string) error {
+
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreateEmailVerification(
+		ctx,
+		database.CreateEmailVerificationParams{
+			TokenHash:       auth.HashOneTimeToken(token),
+			UserID:          userID,
+			Email:           addr,
+			LifetimeSeconds: cfg.lifetimes.emailVerification.Seconds(),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to save email verification: %w", err)
+	}
+
+	cfg.queueEmail(email.Message{
+		To:      addr,
+		Subject: "Confirm your email for Chirpy",
+		Body: fmt.Sprintf(
+			"Use this link within %s to confirm this is your address:\n\n%s\n\n"+
+				"If you didn't sign up for Chirpy, you can ignore this email.\n",
+			cfg.lifetimes.emailVerification, cfg.appLink("/verify-email", token),
+		),
+	})
+
+	return nil
+}
+
+func (cfg *apiConfig) handleVerifyEmail(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	type response struct {
+		User
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
+	// the token is only spent if the address is actually switched over, so a
+	// conflict leaves it usable once the other account lets the address go
+	var user database.User
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		verification, err := q.ConsumeEmailVerification(req.Context(), auth.HashOneTimeToken(params.Token))
+		if err != nil {
+			return fmt.Errorf("error in consuming email verification: %w", err)
+		}
+
+		// no rows here means the user has since moved on to a different address
+		user, err = q.VerifyUserEmail(
+			req.Context(),
+			database.VerifyUserEmailParams{Email: verification.Email, ID: verification.UserID},
+		)
+		if err != nil {
+			return fmt.Errorf("error in verifying email: %w", err)
+		}
+
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid or expired verification token",
+			nil,
+		)
+		return
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		respondWithError(
+			resp,
+			http.StatusConflict,
+			"Email already in use",
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to verify email",
+			err,
+		)
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, response{User: databaseUserToUser(user)})
+
+}
+
+// handleResendEmailVerification sends a fresh link for the pending address,
+// or for the current one if it was never verified
+func (cfg *apiConfig) handleResendEmailVerification(resp http.ResponseWriter, req *http.Request) {
+
+	userID := requiredIdentity(req).UserID
+
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to send verification email",
+			fmt.Errorf("error in retrieving user %s: %s", userID, err),
+		)
+		return
+	}
+
+	addr := user.PendingEmail.String
+	if !user.PendingEmail.Valid {
+		if user.EmailVerified {
+			respondWithError(
+				resp,
+				http.StatusConflict,
+				"Email already verified",
+				nil,
+			)
+			return
+		}
+		addr = user.Email
+	}
+
+	if err := cfg.sendEmailVerification(req.Context(), user.ID, addr); err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to send verification email",
+			fmt.Errorf("error in sending verification for %s: %s", user.ID, err),
+		)
+		return
+	}
+
+	resp.WriteHeader(http.StatusAccepted)
+
+}
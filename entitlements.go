@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// perksFor looks up what the user's current tier allows. Tier comes from
+// is_chirpy_red, which the subscription queries keep up to date.
+func (cfg *apiConfig) perksFor(ctx context.Context, userID uuid.UUID) (entitlements.Tier, entitlements.Perks, error) {
+
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", entitlements.Perks{}, fmt.Errorf("error in retrieving user %s: %w", userID, err)
+	}
+
+	tier := entitlements.TierFor(user.IsChirpyRed)
+
+	return tier, cfg.entitlements.For(tier), nil
+}
+
+// checkChirpRate responds with 429 and returns false once the user has
+// posted their hourly allowance of chirps
+func (cfg *apiConfig) checkChirpRate(resp http.ResponseWriter, req *http.Request, userID uuid.UUID, perks entitlements.Perks) bool {
+
+	if perks.ChirpsPerHour == 0 {
+		return true
+	}
+
+	recent, err := cfg.db.CountRecentChirps(
+		req.Context(),
+		database.CountRecentChirpsParams{UserID: userID, WindowSeconds: time.Hour.Seconds()},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to create chirp",
+			fmt.Errorf("error in counting recent chirps: %s", err),
+		)
+		return false
+	}
+
+	if recent >= int64(perks.ChirpsPerHour) {
+		respondWithError(
+			resp,
+			http.StatusTooManyRequests,
+			fmt.Sprintf("Limit of %d chirps per hour reached", perks.ChirpsPerHour),
+			nil,
+		)
+		return false
+	}
+
+	return true
+}
+
+func (cfg *apiConfig) handleGetEntitlements(resp http.ResponseWriter, req *http.Request) {
+
+	type response struct {
+		Tier  entitlements.Tier  `json:"tier"`
+		Perks entitlements.Perks `json:"perks"`
+	}
+
+	userID := requiredIdentity(req).UserID
+
+	tier, perks, err := cfg.perksFor(req.Context(), userID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve entitlements",
+			err,
+		)
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, response{Tier: tier, Perks: perks})
+
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+func (cfg *apiConfig) handleFollowUser(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, true)
+}
+
+func (cfg *apiConfig) handleUnfollowUser(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, false)
+}
+
+func (cfg *apiConfig) setFollow(resp http.ResponseWriter, req *http.Request, follow bool) {
+
+	userID := requiredIdentity(req).UserID
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid user ID",
+			nil,
+		)
+
+		return
+	}
+
+	if !follow {
+		err = cfg.db.UnfollowUser(
+			req.Context(),
+			database.UnfollowUserParams{FollowerID: userID, FolloweeID: followeeID},
+		)
+		if err != nil {
+			respondWithError(
+				resp,
+				http.StatusInternalServerError,
+				"Unable to unfollow user",
+				err,
+			)
+
+			return
+		}
+
+		resp.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if followeeID == userID {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Users can't follow themselves",
+			nil,
+		)
+
+		return
+	}
+
+	_, err = cfg.db.GetUserByID(req.Context(), followeeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(
+				resp,
+				http.StatusNotFound,
+				"Unable to find user",
+				err,
+			)
+
+			return
+		}
+
+		respondWithError(resp, http.StatusInternalServerError, "Unable to follow user", err)
+		return
+	}
+
+	err = cfg.db.FollowUser(
+		req.Context(),
+		database.FollowUserParams{FollowerID: userID, FolloweeID: followeeID},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to follow user",
+			err,
+		)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+
+}
+
+// followPageFetcher loads up to limit follows strictly after (ascending) or
+// before (descending) the cursor position, in that scan order
+type followPageFetcher func(
+	ctx context.Context, arg database.GetFollowersBeforeParams, ascending bool,
+) ([]database.GetFollowersBeforeRow, error)
+
+// followRows converts between the row types of the follow queries, which
+// sqlc generates separately but are all the same shape
+func followRows[T ~struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}](rows []T) []database.GetFollowersBeforeRow {
+
+	converted := make([]database.GetFollowersBeforeRow, 0, len(rows))
+	for _, row := range rows {
+		converted = append(converted, database.GetFollowersBeforeRow(row))
+	}
+
+	return converted
+}
+
+func (cfg *apiConfig) handleGetFollowers(resp http.ResponseWriter, req *http.Request) {
+	cfg.respondWithFollowPage(resp, req, func(
+		ctx context.Context, arg database.GetFollowersBeforeParams, ascending bool,
+	) ([]database.GetFollowersBeforeRow, error) {
+		if !ascending {
+			return cfg.db.GetFollowersBefore(ctx, arg)
+		}
+
+		rows, err := cfg.db.GetFollowersAfter(ctx, database.GetFollowersAfterParams(arg))
+		return followRows(rows), err
+	})
+}
+
+func (cfg *apiConfig) handleGetFollowing(resp http.ResponseWriter, req *http.Request) {
+	cfg.respondWithFollowPage(resp, req, func(
+		ctx context.Context, arg database.GetFollowersBeforeParams, ascending bool,
+	) ([]database.GetFollowersBeforeRow, error) {
+		if ascending {
+			rows, err := cfg.db.GetFollowingAfter(ctx, database.GetFollowingAfterParams(arg))
+			return followRows(rows), err
+		}
+
+		rows, err := cfg.db.GetFollowingBefore(ctx, database.GetFollowingBeforeParams(arg))
+		return followRows(rows), err
+	})
+}
+
+// respondWithFollowPage serves one page of a follower or following list,
+// newest first, advertising neighbouring pages with a Link header
+func (cfg *apiConfig) respondWithFollowPage(
+	resp http.ResponseWriter,
+	req *http.Request,
+	fetch followPageFetcher,
+) {
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid user ID",
+			nil,
+		)
+
+		return
+	}
+
+	query := req.URL.Query()
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	cursor := pagination.Cursor{
+		CreatedAt: endOfTime,
+		ID:        uuid.Max,
+		Direction: pagination.DirectionNext,
+	}
+	hasCursor := query.Get("cursor") != ""
+	if hasCursor {
+		cursor, err = pagination.DecodeCursor(query.Get("cursor"))
+		if err != nil {
+			respondWithError(resp, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+	}
+
+	// the listing is newest first, so going back to earlier pages scans
+	// forwards in time and the page is flipped before it is returned
+	ascending := cursor.Direction == pagination.DirectionPrev
+
+	rows, err := fetch(req.Context(), database.GetFollowersBeforeParams{
+		UserID:          userID,
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageLimit:       int32(limit + 1),
+	}, ascending)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve follows",
+			err,
+		)
+
+		return
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	if ascending {
+		slices.Reverse(rows)
+	}
+
+	if len(rows) > 0 {
+		first, last := rows[0], rows[len(rows)-1]
+
+		var links []string
+		if hasMore || ascending {
+			next := pagination.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.UserID,
+				Direction: pagination.DirectionNext,
+			}
+			links = append(links, pageLink(req, next, "next"))
+		}
+
+		if (ascending && hasMore) || (!ascending && hasCursor) {
+			prev := pagination.Cursor{
+				CreatedAt: first.CreatedAt,
+				ID:        first.UserID,
+				Direction: pagination.DirectionPrev,
+			}
+			links = append(links, pageLink(req, prev, "prev"))
+		}
+
+		if len(links) > 0 {
+			resp.Header().Set("Link", strings.Join(links, ", "))
+		}
+	}
+
+	follows := []Follow{}
+	for _, row := range rows {
+		follows = append(follows, Follow{UserID: row.UserID, FollowedAt: row.CreatedAt})
+	}
+
+	respondWithJSON(resp, http.StatusOK, follows)
+
+}
+
+func (cfg *apiConfig) handleGetTimeline(resp http.ResponseWriter, req *http.Request) {
+
+	userID := requiredIdentity(req).UserID
+
+	cfg.respondWithChirpPage(resp, req, true, func(
+		ctx context.Context, cursor pagination.Cursor, ascending bool, limit int32,
+	) ([]Chirp, error) {
+		if ascending {
+			return databaseChirpsToChirps(cfg.db.GetTimelineAfter(ctx, database.GetTimelineAfterParams{
+				UserID:          userID,
+				CursorCreatedAt: cursor.CreatedAt,
+				CursorID:        cursor.ID,
+				PageLimit:       limit,
+			}))
+		}
+
+		return databaseChirpsToChirps(cfg.db.GetTimelineBefore(ctx, database.GetTimelineBeforeParams{
+			UserID:          userID,
+			CursorCreatedAt: cursor.CreatedAt,
+			CursorID:        cursor.ID,
+			PageLimit:       limit,
+		}))
+	})
+
+}
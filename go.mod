@@ -10,5 +10,24 @@ require (
 
 require (
 	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/crypto v0.31.0
+	golang.org/x/text v0.21.0
+)
+
+require github.com/gorilla/websocket v1.5.3
+
+require github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_golang v1.20.5
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
 )
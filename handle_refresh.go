@@ -1,16 +1,26 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/adamsma/webserver/internal/auth"
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerRefreshToken(resp http.ResponseWriter, req *http.Request) {
 
 	type response struct {
-		Token string `json:"token"`
+		Token                 string       `json:"token"`
+		ExpiresAt             time.Time    `json:"expires_at"`
+		RefreshToken          string       `json:"refresh_token"`
+		RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+		Scopes                []auth.Scope `json:"scopes"`
 	}
 
 	refreshToken, err := auth.GetBearerToken(req.Header)
@@ -24,34 +34,108 @@ func (cfg *apiConfig) handlerRefreshToken(resp http.ResponseWriter, req *http.Re
 		return
 	}
 
-	details, err := cfg.db.GetRefreshToken(req.Context(), refreshToken)
+	details, err := cfg.db.GetRefreshToken(req.Context(), auth.HashRefreshToken(refreshToken))
 	if err != nil {
 		respondWithError(
 			resp,
 			http.StatusUnauthorized,
 			"Invalid refresh token",
 			fmt.Errorf(
-				"unable to retrieve refresh token information (%s): %s", refreshToken, err,
+				"unable to retrieve refresh token information: %s", err,
 			),
 		)
 
 		return
 	}
 
-	if details.IsExpired || details.RevokedAt.Valid {
+	// a revoked token coming back means it was copied before it was rotated
+	if details.RevokedAt.Valid {
+		cfg.revokeRefreshTokenFamily(req, details.UserID, details.FamilyID)
 		respondWithError(
 			resp,
 			http.StatusUnauthorized,
 			"Invalid refresh token",
 			fmt.Errorf(
-				"expired or revoked refresh token attemp: %+v", details,
+				"revoked refresh token attempt: %+v", details,
 			),
 		)
 
 		return
 	}
 
-	newToken, err := auth.MakeJWT(details.UserID, cfg.secret)
+	if details.IsExpired {
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
+			"Invalid refresh token",
+			fmt.Errorf(
+				"expired refresh token attempt: %+v", details,
+			),
+		)
+
+		return
+	}
+
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to generate refresh token",
+			err,
+		)
+
+		return
+	}
+
+	rotated, err := cfg.db.RotateRefreshToken(
+		req.Context(),
+		database.RotateRefreshTokenParams{
+			OldTokenHash:    auth.HashRefreshToken(refreshToken),
+			NewTokenHash:    auth.HashRefreshToken(newRefreshToken),
+			LifetimeSeconds: cfg.lifetimes.refresh.Seconds(),
+		},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		// another request rotated (or revoked) the token since it was read
+		cfg.revokeRefreshTokenFamily(req, details.UserID, details.FamilyID)
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
+			"Invalid refresh token",
+			fmt.Errorf(
+				"refresh token used concurrently: %+v", details,
+			),
+		)
+
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to generate refresh token",
+			fmt.Errorf("error in rotating refresh token: %s", err),
+		)
+
+		return
+	}
+
+	// rotated tokens keep the scopes granted at login
+	scopes, err := auth.ParseScopes(rotated.Scopes)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to generate authorization token",
+			fmt.Errorf("refresh token has invalid scopes: %s", err),
+		)
+
+		return
+	}
+
+	expiresAt := cfg.lifetimes.accessExpiry(0)
+	newToken, err := auth.MakeJWT(rotated.UserID, cfg.keys, expiresAt, scopes)
 	if err != nil {
 		respondWithError(
 			resp,
@@ -66,7 +150,34 @@ func (cfg *apiConfig) handlerRefreshToken(resp http.ResponseWriter, req *http.Re
 	respondWithJSON(
 		resp,
 		http.StatusOK,
-		response{Token: newToken},
+		response{
+			Token:                 newToken,
+			ExpiresAt:             expiresAt,
+			RefreshToken:          newRefreshToken,
+			RefreshTokenExpiresAt: rotated.ExpiresAt,
+			Scopes:                scopes,
+		},
+	)
+
+}
+
+// revokeRefreshTokenFamily shuts down every session descended from the login
+// that issued a reused token, since either the legitimate client or an
+// attacker holds a copy of it
+func (cfg *apiConfig) revokeRefreshTokenFamily(req *http.Request, userID, familyID uuid.UUID) {
+
+	revoked, err := cfg.db.RevokeRefreshTokenFamily(req.Context(), familyID)
+	if err != nil {
+		log.Printf(
+			"SECURITY: refresh token reuse for user %s (family %s) but unable to revoke family: %s",
+			userID, familyID, err,
+		)
+		return
+	}
+
+	log.Printf(
+		"SECURITY: refresh token reuse for user %s from %s; revoked %d active token(s) in family %s",
+		userID, req.RemoteAddr, revoked, familyID,
 	)
 
 }
@@ -84,14 +195,14 @@ func (cfg *apiConfig) handlerRevokeRefresh(resp http.ResponseWriter, req *http.R
 		return
 	}
 
-	err = cfg.db.RevokeRefreshToken(req.Context(), refreshToken)
+	err = cfg.db.RevokeRefreshToken(req.Context(), auth.HashRefreshToken(refreshToken))
 	if err != nil {
 		respondWithError(
 			resp,
 			http.StatusUnauthorized,
 			"Unable to revoke refresh token",
 			fmt.Errorf(
-				"unable to revoke refresh token (%s): %s", refreshToken, err,
+				"unable to revoke refresh token: %s", err,
 			),
 		)
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,20 +17,67 @@ type TokenType string
 const (
 	// TokenTypeAccess -
 	TokenTypeAccess TokenType = "chirpy-access"
+	// TokenTypeMFAChallenge - proves the password step of a two-factor login
+	TokenTypeMFAChallenge TokenType = "chirpy-mfa"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
+// Claims is what a validated access token says about its bearer
+type Claims struct {
+	UserID    uuid.UUID
+	Scopes    []Scope
+	ExpiresAt time.Time
+}
+
+func (c Claims) HasScope(scope Scope) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+type accessTokenClaims struct {
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// MakeJWT signs an access token with the ring's primary key, naming the key
+// in the kid header so it can still be verified after the primary changes
+func MakeJWT(userID uuid.UUID, keys *KeyRing, expiresAt time.Time, scopes []Scope) (string, error) {
+	return makeToken(TokenTypeAccess, userID, keys, expiresAt, scopes)
+}
+
+func ValidateJWT(tokenString string, keys *KeyRing) (Claims, error) {
+	return validateToken(TokenTypeAccess, tokenString, keys)
+}
+
+// MakeMFAChallenge is issued in place of an access token when the password
+// was right but a second factor is still needed. It carries the scopes the
+// login asked for so they survive to the final token.
+func MakeMFAChallenge(userID uuid.UUID, keys *KeyRing, expiresAt time.Time, scopes []Scope) (string, error) {
+	return makeToken(TokenTypeMFAChallenge, userID, keys, expiresAt, scopes)
+}
 
-	claims := &jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
-		Subject:   userID.String(),
+func ValidateMFAChallenge(tokenString string, keys *KeyRing) (Claims, error) {
+	return validateToken(TokenTypeMFAChallenge, tokenString, keys)
+}
+
+func makeToken(tokenType TokenType, userID uuid.UUID, keys *KeyRing, expiresAt time.Time, scopes []Scope) (string, error) {
+
+	if len(scopes) == 0 {
+		return "", errors.New("access tokens need at least one scope")
+	}
+
+	claims := &accessTokenClaims{
+		Scope: joinScopes(scopes),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(tokenType),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Subject:   userID.String(),
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(keys.primary.method, claims)
+	token.Header["kid"] = keys.primary.ID
 
-	signed, err := token.SignedString([]byte(tokenSecret))
+	signed, err := token.SignedString(keys.primary.signKey)
 	if err != nil {
 		return "", fmt.Errorf("unable to sign token: %w", err)
 	}
@@ -37,33 +85,40 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 	return signed, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+// validateToken also checks the issuer, so a challenge token can never be
+// used as an access token or the other way around
+func validateToken(tokenType TokenType, tokenString string, keys *KeyRing) (Claims, error) {
 
-	token, err := jwt.ParseWithClaims(
+	parsed := &accessTokenClaims{}
+	_, err := jwt.ParseWithClaims(
 		tokenString,
-		&jwt.RegisteredClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(tokenSecret), nil
-		},
+		parsed,
+		keys.verificationKey,
 	)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid token: %w", err)
+		return Claims{}, fmt.Errorf("invalid token: %w", err)
 	}
 
-	id, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.Nil, err
+	if parsed.Issuer != string(tokenType) {
+		return Claims{}, errors.New("invalid issuer")
 	}
 
-	issuer, err := token.Claims.GetIssuer()
+	id, err := uuid.Parse(parsed.Subject)
 	if err != nil {
-		return uuid.Nil, err
+		return Claims{}, err
 	}
-	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+
+	claims := Claims{UserID: id, Scopes: splitScopes(parsed.Scope)}
+	if parsed.ExpiresAt != nil {
+		claims.ExpiresAt = parsed.ExpiresAt.Time
+	}
+
+	// tokens issued before scopes existed carried full access
+	if len(claims.Scopes) == 0 {
+		claims.Scopes = DefaultScopes()
 	}
 
-	return uuid.Parse(id)
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
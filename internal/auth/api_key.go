@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"strings"
@@ -20,3 +21,9 @@ func GetAPIKey(headers http.Header) (string, error) {
 	return auth[1], nil
 
 }
+
+// ValidAPIKey compares keys in constant time. Nothing matches an empty
+// expected key, so an unset key disables the endpoint rather than opening it.
+func ValidAPIKey(presented, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(expected)) == 1
+}
@@ -3,9 +3,12 @@ package auth
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -69,15 +72,16 @@ func TestTokenCreateAndValidate(t *testing.T) {
 
 	id1 := uuid.New()
 	id2 := uuid.New()
-	secret1 := "realsecret"
-	secret2 := "anotherSecret"
+	secret1 := hmacKeyRing(t, "realsecret")
+	secret2 := hmacKeyRing(t, "anotherSecret")
 
 	var tokenId1Sec1, expiredToken, tokenId2Sec1, tokenId1Sec2 string
 
 	createTests := []struct {
 		name      string
 		id        uuid.UUID
-		secret    string
+		secret    *KeyRing
+		expiresIn time.Duration
 		saveToken *string
 		wantErr   bool
 	}{
@@ -85,6 +89,7 @@ func TestTokenCreateAndValidate(t *testing.T) {
 			name:      "Create Valid Token",
 			id:        id1,
 			secret:    secret1,
+			expiresIn: time.Hour,
 			saveToken: &tokenId1Sec1,
 			wantErr:   false,
 		},
@@ -92,6 +97,7 @@ func TestTokenCreateAndValidate(t *testing.T) {
 			name:      "Create Valid Token 2",
 			id:        id2,
 			secret:    secret1,
+			expiresIn: time.Hour,
 			saveToken: &tokenId2Sec1,
 			wantErr:   false,
 		},
@@ -99,14 +105,23 @@ func TestTokenCreateAndValidate(t *testing.T) {
 			name:      "Create Valid Token 3",
 			id:        id1,
 			secret:    secret2,
+			expiresIn: time.Hour,
 			saveToken: &tokenId1Sec2,
 			wantErr:   false,
 		},
+		{
+			name:      "Create Expired Token",
+			id:        id1,
+			secret:    secret1,
+			expiresIn: -time.Minute,
+			saveToken: &expiredToken,
+			wantErr:   false,
+		},
 	}
 
 	for _, test := range createTests {
 		t.Run(test.name, func(t *testing.T) {
-			token, err := MakeJWT(test.id, test.secret)
+			token, err := MakeJWT(test.id, test.secret, time.Now().Add(test.expiresIn), DefaultScopes())
 			if (err != nil) != test.wantErr {
 				t.Errorf("Make JWT error == %v, expected %v", err, test.wantErr)
 			}
@@ -133,7 +148,7 @@ func TestTokenCreateAndValidate(t *testing.T) {
 	validateTests := []struct {
 		name        string
 		tokenString string
-		secret      string
+		secret      *KeyRing
 		id          uuid.UUID
 		wantErr     bool
 	}{
@@ -184,29 +199,159 @@ func TestTokenCreateAndValidate(t *testing.T) {
 	for _, test := range validateTests {
 		t.Run(test.name, func(t *testing.T) {
 			// t.Logf("Test %d Atempting to validate token %v", i, test.tokenString)
-			id, err := ValidateJWT(test.tokenString, test.secret)
+			claims, err := ValidateJWT(test.tokenString, test.secret)
 			if (err != nil) != test.wantErr {
 				t.Errorf("Validate JWT error == %v, expected %v", err, test.wantErr)
 			}
 
-			if id != test.id && !test.wantErr {
-				t.Errorf("expected user ID: %v,  actual user ID: %v", test.id, id)
+			if claims.UserID != test.id && !test.wantErr {
+				t.Errorf("expected user ID: %v,  actual user ID: %v", test.id, claims.UserID)
 			}
 		})
 	}
 
 	// test same ID's are returned with different secrets
 	t.Run("Different JWTs, Same ID", func(t *testing.T) {
-		idJWT1, _ := ValidateJWT(tokenId1Sec1, secret1)
-		idJWT2, _ := ValidateJWT(tokenId1Sec2, secret2)
+		claimsJWT1, _ := ValidateJWT(tokenId1Sec1, secret1)
+		claimsJWT2, _ := ValidateJWT(tokenId1Sec2, secret2)
+
+		if claimsJWT1.UserID != claimsJWT2.UserID {
+			t.Errorf("user ID claims should match - id1: %v, id2: %v", claimsJWT1.UserID, claimsJWT2.UserID)
+		}
+	})
+
+}
+
+func TestTokenScopes(t *testing.T) {
+
+	keys := hmacKeyRing(t, "realsecret")
+	userID := uuid.New()
+
+	limited, err := MakeJWT(userID, keys, time.Now().Add(time.Hour), []Scope{ScopeChirpsWrite})
+	if err != nil {
+		t.Fatalf("error in making JWT: %v", err)
+	}
+
+	// tokens signed before the scope claim was added
+	unscoped := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	unscopedToken, _ := unscoped.SignedString([]byte("realsecret"))
+
+	tests := []struct {
+		name    string
+		token   string
+		scope   Scope
+		allowed bool
+	}{
+		{name: "Granted Scope", token: limited, scope: ScopeChirpsWrite, allowed: true},
+		{name: "Missing Scope", token: limited, scope: ScopeUsersWrite, allowed: false},
+		{name: "Unscoped Token Has Defaults", token: unscopedToken, scope: ScopeUsersWrite, allowed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := ValidateJWT(test.token, keys)
+			if err != nil {
+				t.Fatalf("error in validating JWT: %v", err)
+			}
+			if claims.HasScope(test.scope) != test.allowed {
+				t.Errorf("HasScope(%s) == %v, expected %v", test.scope, !test.allowed, test.allowed)
+			}
+		})
+	}
 
-		if idJWT1 != idJWT2 {
-			t.Errorf("user ID claims should match - id1: %v, id2: %v", idJWT1, idJWT2)
+	t.Run("No Scopes", func(t *testing.T) {
+		if _, err := MakeJWT(userID, keys, time.Now().Add(time.Hour), nil); err == nil {
+			t.Error("token without scopes should not be issued")
 		}
 	})
 
 }
 
+func TestMFAChallenge(t *testing.T) {
+
+	keys := hmacKeyRing(t, "realsecret")
+	userID := uuid.New()
+	expiresAt := time.Now().Add(5 * time.Minute)
+
+	challenge, _ := MakeMFAChallenge(userID, keys, expiresAt, []Scope{ScopeChirpsRead})
+	access, _ := MakeJWT(userID, keys, expiresAt, []Scope{ScopeChirpsRead})
+
+	t.Run("Challenge Validates", func(t *testing.T) {
+		claims, err := ValidateMFAChallenge(challenge, keys)
+		if err != nil {
+			t.Fatalf("error in validating challenge: %v", err)
+		}
+		if claims.UserID != userID || !slices.Equal(claims.Scopes, []Scope{ScopeChirpsRead}) {
+			t.Errorf("unexpected claims: %+v", claims)
+		}
+	})
+
+	t.Run("Challenge Is Not An Access Token", func(t *testing.T) {
+		if _, err := ValidateJWT(challenge, keys); err == nil {
+			t.Error("challenge token should not validate as an access token")
+		}
+	})
+
+	t.Run("Access Token Is Not A Challenge", func(t *testing.T) {
+		if _, err := ValidateMFAChallenge(access, keys); err == nil {
+			t.Error("access token should not validate as a challenge")
+		}
+	})
+
+}
+
+func TestParseScopes(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		requested []string
+		expected  []Scope
+		wantErr   bool
+	}{
+		{name: "Default", requested: nil, expected: DefaultScopes()},
+		{name: "Subset", requested: []string{"chirps:read"}, expected: []Scope{ScopeChirpsRead}},
+		{name: "Users Read", requested: []string{"users:read"}, expected: []Scope{ScopeUsersRead}},
+		{
+			name:      "Duplicates Removed",
+			requested: []string{"chirps:write", "chirps:write"},
+			expected:  []Scope{ScopeChirpsWrite},
+		},
+		{name: "Unknown Scope", requested: []string{"admin"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scopes, err := ParseScopes(test.requested)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseScopes error == %v, expected %v", err, test.wantErr)
+			}
+			if !slices.Equal(scopes, test.expected) {
+				t.Errorf("expected: %v, actual: %v", test.expected, scopes)
+			}
+		})
+	}
+
+}
+
+func hmacKeyRing(t *testing.T, secret string) *KeyRing {
+
+	key, err := NewSigningKey(LegacyKeyID, "HS256", KeyPrimary, []byte(secret))
+	if err != nil {
+		t.Fatalf("error in creating signing key: %v", err)
+	}
+
+	keys, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatalf("error in creating key ring: %v", err)
+	}
+
+	return keys
+}
+
 func TestGetBearerToken(t *testing.T) {
 
 	req, err := http.NewRequest("GET", "http://example.com", nil)
@@ -253,3 +398,65 @@ func TestGetBearerToken(t *testing.T) {
 	})
 
 }
+
+func TestHashRefreshToken(t *testing.T) {
+
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("error in making refresh token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		hash  string
+	}{
+		{
+			name:  "Known Value",
+			token: "abc",
+			hash:  "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		},
+		{
+			name:  "Deterministic",
+			token: token,
+			hash:  HashRefreshToken(token),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash := HashRefreshToken(test.token)
+			if hash != test.hash {
+				t.Errorf("expected: '%s', actual: '%s'", test.hash, hash)
+			}
+			if hash == test.token {
+				t.Error("hash should not equal the raw token")
+			}
+		})
+	}
+
+}
+
+func TestValidAPIKey(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		presented string
+		expected  string
+		valid     bool
+	}{
+		{name: "Match", presented: "f271c81ff7084ee5b99a5091b42d486e", expected: "f271c81ff7084ee5b99a5091b42d486e", valid: true},
+		{name: "Mismatch", presented: "f271c81ff7084ee5b99a5091b42d486f", expected: "f271c81ff7084ee5b99a5091b42d486e", valid: false},
+		{name: "Prefix", presented: "f271c81f", expected: "f271c81ff7084ee5b99a5091b42d486e", valid: false},
+		{name: "Nothing Configured", presented: "", expected: "", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ValidAPIKey(test.presented, test.expected); got != test.valid {
+				t.Errorf("ValidAPIKey == %v, expected %v", got, test.valid)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JSONWebKey is the public half of a signing key as described in RFC 7517
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA
+	Modulus  string `json:"n,omitempty"`
+	Exponent string `json:"e,omitempty"`
+
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS lists the public keys that tokens may currently be verified with.
+// Shared secrets and retired keys are never published.
+func (r *KeyRing) JWKS() JSONWebKeySet {
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	for _, kid := range r.order {
+		key := r.keys[kid]
+		if key.Status == KeyRetired {
+			continue
+		}
+
+		jwk := JSONWebKey{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.Modulus = encodeJWKBytes(public.N.Bytes())
+			jwk.Exponent = encodeJWKBytes(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			// coordinates are left padded to the curve size (RFC 7518 6.2.1.2)
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.KeyType = "EC"
+			jwk.Curve = public.Curve.Params().Name
+			jwk.X = encodeJWKBytes(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = encodeJWKBytes(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = encodeJWKBytes(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func encodeJWKBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type KeyStatus string
+
+const (
+	// KeyPrimary - signs new tokens; exactly one key in a ring is primary
+	KeyPrimary KeyStatus = "primary"
+	// KeyActive - verifies tokens but doesn't sign new ones
+	KeyActive KeyStatus = "active"
+	// KeyRetired - kept for reference; tokens signed with it are rejected
+	KeyRetired KeyStatus = "retired"
+)
+
+// LegacyKeyID is assumed for tokens without a kid header, which were all
+// signed with JWT_SIGN_SECRET before key rotation was supported
+const LegacyKeyID = "default"
+
+type SigningKey struct {
+	ID     string
+	Status KeyStatus
+
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewSigningKey builds a key for one of HS256, RS256, ES256 or EdDSA. For
+// HS256 material is the shared secret; otherwise it's a PEM encoded private
+// key of the matching type.
+func NewSigningKey(id, alg string, status KeyStatus, material []byte) (SigningKey, error) {
+
+	key := SigningKey{ID: id, Status: status}
+
+	switch status {
+	case KeyPrimary, KeyActive, KeyRetired:
+	default:
+		return SigningKey{}, fmt.Errorf("key %s: unknown status %q", id, status)
+	}
+
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		if len(material) == 0 {
+			return SigningKey{}, fmt.Errorf("key %s: empty secret", id)
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = material
+		key.verifyKey = material
+
+	case jwt.SigningMethodRS256.Alg():
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(material)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		if private.N.BitLen() < 2048 {
+			return SigningKey{}, fmt.Errorf("key %s: RSA keys must be at least 2048 bits", id)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.signKey = private
+		key.verifyKey = &private.PublicKey
+
+	case jwt.SigningMethodES256.Alg():
+		private, err := jwt.ParseECPrivateKeyFromPEM(material)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		if private.Curve != elliptic.P256() {
+			return SigningKey{}, fmt.Errorf("key %s: ES256 requires a P-256 key", id)
+		}
+		key.method = jwt.SigningMethodES256
+		key.signKey = private
+		key.verifyKey = &private.PublicKey
+
+	case jwt.SigningMethodEdDSA.Alg():
+		private, err := jwt.ParseEdPrivateKeyFromPEM(material)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		key.method = jwt.SigningMethodEdDSA
+		key.signKey = private
+		key.verifyKey = private.(crypto.Signer).Public()
+
+	default:
+		return SigningKey{}, fmt.Errorf("key %s: unsupported algorithm %q", id, alg)
+	}
+
+	return key, nil
+}
+
+func (k SigningKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+type KeyRing struct {
+	primary *SigningKey
+	keys    map[string]*SigningKey
+	// kids in the order they were added, so the JWKS output is stable
+	order []string
+}
+
+func NewKeyRing(keys ...SigningKey) (*KeyRing, error) {
+
+	ring := &KeyRing{keys: map[string]*SigningKey{}}
+
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing key has no id")
+		}
+		if _, ok := ring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+
+		key := key
+		ring.keys[key.ID] = &key
+		ring.order = append(ring.order, key.ID)
+
+		if key.Status == KeyPrimary {
+			if ring.primary != nil {
+				return nil, fmt.Errorf(
+					"keys %s and %s are both primary", ring.primary.ID, key.ID,
+				)
+			}
+			ring.primary = &key
+		}
+	}
+
+	if ring.primary == nil {
+		return nil, errors.New("key ring has no primary key")
+	}
+
+	return ring, nil
+}
+
+// verificationKey finds the key a token claims to be signed with, refusing
+// retired keys and any algorithm other than the one the key was created for
+func (r *KeyRing) verificationKey(token *jwt.Token) (interface{}, error) {
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = LegacyKeyID
+	}
+
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if key.Status == KeyRetired {
+		return nil, fmt.Errorf("signing key %q has been retired", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf(
+			"signing key %q uses %s, token uses %s", kid, key.method.Alg(), token.Method.Alg(),
+		)
+	}
+
+	return key.verifyKey, nil
+}
+
+func LoadSigningKeys(path string) ([]SigningKey, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open signing key list: %w", err)
+	}
+	defer f.Close()
+
+	return ParseSigningKeys(f, filepath.Dir(path))
+}
+
+// ParseSigningKeys reads a key list with one key per line in the form
+//
+//	<kid> <alg> <status> <pem file>
+//
+// where status is one of primary, active or retired and relative pem paths
+// are resolved against dir. Blank lines and lines starting with # are
+// ignored.
+func ParseSigningKeys(r io.Reader, dir string) ([]SigningKey, error) {
+
+	var keys []SigningKey
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf(
+				"line %d: expected '<kid> <alg> <status> <pem file>', got %q", lineNum, line,
+			)
+		}
+
+		if fields[1] == jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf(
+				"line %d: shared secrets can't be loaded from a key list, use JWT_SIGN_SECRET", lineNum,
+			)
+		}
+
+		pemPath := fields[3]
+		if !filepath.IsAbs(pemPath) {
+			pemPath = filepath.Join(dir, pemPath)
+		}
+
+		material, err := os.ReadFile(pemPath)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: unable to read key: %w", lineNum, err)
+		}
+
+		key, err := NewSigningKey(fields[0], fields[1], KeyStatus(fields[2]), material)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read signing key list: %w", err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func privateKeyPEM(t *testing.T, alg string) []byte {
+
+	var private interface{}
+	var err error
+
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("error in generating %s key: %v", alg, err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("error in encoding %s key: %v", alg, err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func signingKey(t *testing.T, id, alg string, status KeyStatus) SigningKey {
+
+	material := []byte("shared-secret-" + id)
+	if alg != "HS256" {
+		material = privateKeyPEM(t, alg)
+	}
+
+	key, err := NewSigningKey(id, alg, status, material)
+	if err != nil {
+		t.Fatalf("error in creating signing key %s: %v", id, err)
+	}
+
+	return key
+}
+
+func TestKeyRingAlgorithms(t *testing.T) {
+
+	userID := uuid.New()
+
+	for _, alg := range []string{"HS256", "RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+
+			keys, err := NewKeyRing(signingKey(t, "k1", alg, KeyPrimary))
+			if err != nil {
+				t.Fatalf("error in creating key ring: %v", err)
+			}
+
+			token, err := MakeJWT(userID, keys, time.Now().Add(time.Hour), DefaultScopes())
+			if err != nil {
+				t.Fatalf("error in making JWT: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("error in parsing JWT: %v", err)
+			}
+			if parsed.Header["kid"] != "k1" || parsed.Header["alg"] != alg {
+				t.Errorf("unexpected header: %v", parsed.Header)
+			}
+
+			claims, err := ValidateJWT(token, keys)
+			if err != nil {
+				t.Fatalf("error in validating JWT: %v", err)
+			}
+			if claims.UserID != userID {
+				t.Errorf("expected user ID: %v, actual user ID: %v", userID, claims.UserID)
+			}
+		})
+	}
+
+}
+
+func TestKeyRingRotation(t *testing.T) {
+
+	userID := uuid.New()
+	legacy := signingKey(t, LegacyKeyID, "HS256", KeyPrimary)
+	first := signingKey(t, "2024-01", "ES256", KeyPrimary)
+	second := signingKey(t, "2024-07", "EdDSA", KeyPrimary)
+
+	legacyRing, _ := NewKeyRing(legacy)
+	firstRing, _ := NewKeyRing(first)
+
+	legacyToken, _ := MakeJWT(userID, legacyRing, time.Now().Add(time.Hour), DefaultScopes())
+	firstToken, _ := MakeJWT(userID, firstRing, time.Now().Add(time.Hour), DefaultScopes())
+
+	// tokens issued before kid headers existed
+	unversioned := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:  string(TokenTypeAccess),
+		Subject: userID.String(),
+	})
+	unversionedToken, _ := unversioned.SignedString([]byte("shared-secret-" + LegacyKeyID))
+
+	legacy.Status = KeyActive
+	first.Status = KeyActive
+	rotated, err := NewKeyRing(legacy, first, second)
+	if err != nil {
+		t.Fatalf("error in creating key ring: %v", err)
+	}
+
+	first.Status = KeyRetired
+	retired, err := NewKeyRing(legacy, first, second)
+	if err != nil {
+		t.Fatalf("error in creating key ring: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		keys    *KeyRing
+		wantErr bool
+	}{
+		{name: "Legacy Key Still Active", token: legacyToken, keys: rotated, wantErr: false},
+		{name: "No kid Uses Legacy Key", token: unversionedToken, keys: rotated, wantErr: false},
+		{name: "Previous Primary Still Active", token: firstToken, keys: rotated, wantErr: false},
+		{name: "Retired Key Rejected", token: firstToken, keys: retired, wantErr: true},
+		{name: "Unknown Key Rejected", token: firstToken, keys: legacyRing, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ValidateJWT(test.token, test.keys)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate JWT error == %v, expected %v", err, test.wantErr)
+			}
+		})
+	}
+
+	t.Run("Algorithm Must Match Key", func(t *testing.T) {
+		// an HS256 token claiming to be signed by an asymmetric key
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Issuer:  string(TokenTypeAccess),
+			Subject: userID.String(),
+		})
+		forged.Header["kid"] = second.ID
+		forgedToken, _ := forged.SignedString([]byte("guess"))
+
+		if _, err := ValidateJWT(forgedToken, rotated); err == nil {
+			t.Error("token with mismatched algorithm should be rejected")
+		}
+	})
+
+}
+
+func TestNewKeyRing(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		keys    []SigningKey
+		wantErr bool
+	}{
+		{
+			name:    "No Primary",
+			keys:    []SigningKey{signingKey(t, "a", "HS256", KeyActive)},
+			wantErr: true,
+		},
+		{
+			name: "Two Primaries",
+			keys: []SigningKey{
+				signingKey(t, "a", "HS256", KeyPrimary),
+				signingKey(t, "b", "HS256", KeyPrimary),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate IDs",
+			keys: []SigningKey{
+				signingKey(t, "a", "HS256", KeyPrimary),
+				signingKey(t, "a", "HS256", KeyActive),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid",
+			keys: []SigningKey{
+				signingKey(t, "a", "HS256", KeyPrimary),
+				signingKey(t, "b", "HS256", KeyRetired),
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewKeyRing(test.keys...)
+			if (err != nil) != test.wantErr {
+				t.Errorf("NewKeyRing error == %v, expected %v", err, test.wantErr)
+			}
+		})
+	}
+
+}
+
+func TestJWKS(t *testing.T) {
+
+	keys, err := NewKeyRing(
+		signingKey(t, LegacyKeyID, "HS256", KeyActive),
+		signingKey(t, "rsa", "RS256", KeyPrimary),
+		signingKey(t, "ec", "ES256", KeyActive),
+		signingKey(t, "ed", "EdDSA", KeyActive),
+		signingKey(t, "old", "ES256", KeyRetired),
+	)
+	if err != nil {
+		t.Fatalf("error in creating key ring: %v", err)
+	}
+
+	set := keys.JWKS()
+
+	expected := []struct {
+		kid   string
+		kty   string
+		alg   string
+		curve string
+	}{
+		{kid: "rsa", kty: "RSA", alg: "RS256"},
+		{kid: "ec", kty: "EC", alg: "ES256", curve: "P-256"},
+		{kid: "ed", kty: "OKP", alg: "EdDSA", curve: "Ed25519"},
+	}
+
+	if len(set.Keys) != len(expected) {
+		t.Fatalf("expected %d keys, actual: %+v", len(expected), set.Keys)
+	}
+
+	for i, want := range expected {
+		got := set.Keys[i]
+		if got.KeyID != want.kid || got.KeyType != want.kty || got.Algorithm != want.alg || got.Curve != want.curve {
+			t.Errorf("expected %+v, actual: %+v", want, got)
+		}
+		if got.Use != "sig" {
+			t.Errorf("expected use 'sig', actual: '%s'", got.Use)
+		}
+	}
+
+	if set.Keys[0].Exponent != "AQAB" {
+		t.Errorf("expected RSA exponent 'AQAB', actual: '%s'", set.Keys[0].Exponent)
+	}
+	if len(set.Keys[1].X) != 43 || len(set.Keys[1].Y) != 43 {
+		t.Errorf("EC coordinates should be 32 bytes: %+v", set.Keys[1])
+	}
+
+}
+
+func TestParseSigningKeys(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ec.pem"), privateKeyPEM(t, "ES256"), 0600); err != nil {
+		t.Fatalf("error in writing key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		list    string
+		count   int
+		wantErr bool
+	}{
+		{
+			name:  "Comments And Blanks",
+			list:  "# kid alg status file\n\n2024-01 ES256 primary ec.pem\n",
+			count: 1,
+		},
+		{
+			name:    "Missing Field",
+			list:    "2024-01 ES256 ec.pem",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown Status",
+			list:    "2024-01 ES256 spare ec.pem",
+			wantErr: true,
+		},
+		{
+			name:    "Wrong Algorithm For Key",
+			list:    "2024-01 RS256 primary ec.pem",
+			wantErr: true,
+		},
+		{
+			name:    "Shared Secret",
+			list:    "2024-01 HS256 primary ec.pem",
+			wantErr: true,
+		},
+		{
+			name:    "Missing File",
+			list:    "2024-01 ES256 primary missing.pem",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := ParseSigningKeys(strings.NewReader(test.list), dir)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseSigningKeys error == %v, expected %v", err, test.wantErr)
+			}
+			if len(keys) != test.count {
+				t.Errorf("expected %d keys, actual: %d", test.count, len(keys))
+			}
+		})
+	}
+
+}
@@ -0,0 +1,12 @@
+package auth
+
+// MakeOneTimeToken returns a token for a link sent by email, such as a
+// password reset or address verification. It has the same shape as a refresh
+// token and, like one, only its hash is stored.
+func MakeOneTimeToken() (string, error) {
+	return MakeRefreshToken()
+}
+
+func HashOneTimeToken(token string) string {
+	return HashRefreshToken(token)
+}
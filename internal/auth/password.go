@@ -1,6 +1,10 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error){
 
@@ -16,4 +20,21 @@ func CheckPasswordHash(password, hash string) error {
 
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 
-}
\ No newline at end of file
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// CheckDummyPassword costs as much as CheckPasswordHash but always fails. Run
+// it when the account doesn't exist so response times don't reveal which
+// emails are registered.
+func CheckDummyPassword(password string) {
+
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	})
+
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 )
@@ -18,3 +19,13 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(b), nil
 
 }
+
+// HashRefreshToken returns the value stored in place of a refresh token. The
+// tokens are 256 bits of randomness, so a plain SHA-256 is enough to make a
+// leaked table useless without slowing down every refresh.
+func HashRefreshToken(token string) string {
+
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+
+}
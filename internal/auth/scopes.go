@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scope limits what an access token can do. There is no admin scope: admin
+// endpoints take the admin API key rather than a user's token, so no login
+// can be granted them.
+type Scope string
+
+const (
+	// ScopeChirpsRead - personalized reads such as the home timeline
+	ScopeChirpsRead Scope = "chirps:read"
+	// ScopeChirpsWrite - posting, editing, deleting and liking chirps
+	ScopeChirpsWrite Scope = "chirps:write"
+	// ScopeUsersRead - reading the user's own account, such as their
+	// subscription and entitlements
+	ScopeUsersRead Scope = "users:read"
+	// ScopeUsersWrite - changing account details and who the user follows
+	ScopeUsersWrite Scope = "users:write"
+)
+
+// DefaultScopes are granted when a login doesn't ask for anything narrower
+func DefaultScopes() []Scope {
+	return []Scope{ScopeChirpsRead, ScopeChirpsWrite, ScopeUsersRead, ScopeUsersWrite}
+}
+
+// ParseScopes checks requested scopes against the known set, removing
+// duplicates. An empty request yields the default scopes.
+func ParseScopes(requested []string) ([]Scope, error) {
+
+	if len(requested) == 0 {
+		return DefaultScopes(), nil
+	}
+
+	known := DefaultScopes()
+	scopes := make([]Scope, 0, len(requested))
+	for _, raw := range requested {
+		scope := Scope(raw)
+		if !slices.Contains(known, scope) {
+			return nil, fmt.Errorf("unknown scope %q", raw)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, nil
+}
+
+// ScopeStrings converts scopes for storage alongside refresh tokens
+func ScopeStrings(scopes []Scope) []string {
+
+	strs := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		strs = append(strs, string(scope))
+	}
+
+	return strs
+}
+
+// the scope claim is a space separated list (RFC 8693 section 4.2)
+func joinScopes(scopes []Scope) string {
+	return strings.Join(ScopeStrings(scopes), " ")
+}
+
+func splitScopes(claim string) []Scope {
+
+	var scopes []Scope
+	for _, field := range strings.Fields(claim) {
+		scopes = append(scopes, Scope(field))
+	}
+
+	return scopes
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+
+	// RFC 4226 appendix D
+	key := []byte("12345678901234567890")
+	expected := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, want := range expected {
+		if got := DefaultTOTP.hotp(key, int64(counter)); got != want {
+			t.Errorf("counter %d - expected: '%s', actual: '%s'", counter, want, got)
+		}
+	}
+
+}
+
+func TestTOTPCode(t *testing.T) {
+
+	// RFC 6238 appendix B; each algorithm uses a seed of its own output size
+	seeds := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	hashes := map[string]func() hash.Hash{
+		"SHA1":   sha1.New,
+		"SHA256": sha256.New,
+		"SHA512": sha512.New,
+	}
+
+	tests := []struct {
+		unix int64
+		alg  string
+		code string
+	}{
+		{unix: 59, alg: "SHA1", code: "94287082"},
+		{unix: 59, alg: "SHA256", code: "46119246"},
+		{unix: 59, alg: "SHA512", code: "90693936"},
+		{unix: 1111111109, alg: "SHA1", code: "07081804"},
+		{unix: 1111111109, alg: "SHA256", code: "68084774"},
+		{unix: 1111111109, alg: "SHA512", code: "25091201"},
+		{unix: 1111111111, alg: "SHA1", code: "14050471"},
+		{unix: 1111111111, alg: "SHA256", code: "67062674"},
+		{unix: 1111111111, alg: "SHA512", code: "99943326"},
+		{unix: 1234567890, alg: "SHA1", code: "89005924"},
+		{unix: 1234567890, alg: "SHA256", code: "91819424"},
+		{unix: 1234567890, alg: "SHA512", code: "93441116"},
+		{unix: 2000000000, alg: "SHA1", code: "69279037"},
+		{unix: 2000000000, alg: "SHA256", code: "90698825"},
+		{unix: 2000000000, alg: "SHA512", code: "38618901"},
+		{unix: 20000000000, alg: "SHA1", code: "65353130"},
+		{unix: 20000000000, alg: "SHA256", code: "77737706"},
+		{unix: 20000000000, alg: "SHA512", code: "47863826"},
+	}
+
+	for _, test := range tests {
+		at := time.Unix(test.unix, 0).UTC()
+		t.Run(test.alg+" "+at.Format(time.RFC3339), func(t *testing.T) {
+
+			totp := TOTP{Digits: 8, Period: 30 * time.Second, Hash: hashes[test.alg]}
+			secret := totpEncoding.EncodeToString([]byte(seeds[test.alg]))
+
+			code, err := totp.Code(secret, at)
+			if err != nil {
+				t.Fatalf("error in generating code: %v", err)
+			}
+			if code != test.code {
+				t.Errorf("expected: '%s', actual: '%s'", test.code, code)
+			}
+
+			if _, ok := totp.Verify(secret, test.code, at); !ok {
+				t.Error("code should verify at the time it was generated")
+			}
+		})
+	}
+
+}
+
+func TestTOTPVerify(t *testing.T) {
+
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("error in generating secret: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	code, _ := DefaultTOTP.Code(secret, now)
+
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		ok   bool
+	}{
+		{name: "Current Period", code: code, at: now, ok: true},
+		{name: "One Period Late", code: code, at: now.Add(30 * time.Second), ok: true},
+		{name: "One Period Early", code: code, at: now.Add(-30 * time.Second), ok: true},
+		{name: "Too Late", code: code, at: now.Add(90 * time.Second), ok: false},
+		{name: "Wrong Length", code: code[:5], at: now, ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := DefaultTOTP.Verify(secret, test.code, test.at)
+			if ok != test.ok {
+				t.Fatalf("Verify == %v, expected %v", ok, test.ok)
+			}
+			if ok && step != DefaultTOTP.Step(now) {
+				t.Errorf("expected step %d, actual: %d", DefaultTOTP.Step(now), step)
+			}
+		})
+	}
+
+}
+
+func TestTOTPURI(t *testing.T) {
+
+	uri := DefaultTOTP.URI("JBSWY3DPEHPK3PXP", "Chirpy", "user@example.com")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("error in parsing URI: %v", err)
+	}
+
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("unexpected URI: %s", uri)
+	}
+	if parsed.Path != "/Chirpy:user@example.com" {
+		t.Errorf("expected label '/Chirpy:user@example.com', actual: '%s'", parsed.Path)
+	}
+
+	query := parsed.Query()
+	expected := map[string]string{
+		"secret":    "JBSWY3DPEHPK3PXP",
+		"issuer":    "Chirpy",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for key, want := range expected {
+		if query.Get(key) != want {
+			t.Errorf("%s - expected: '%s', actual: '%s'", key, want, query.Get(key))
+		}
+	}
+
+}
+
+func TestRecoveryCodes(t *testing.T) {
+
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("error in generating recovery codes: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected recovery code format: '%s'", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code: '%s'", code)
+		}
+		seen[code] = true
+	}
+
+	code := codes[0]
+	variants := []string{code, strings.ToUpper(code), strings.ReplaceAll(code, "-", ""), code[:5] + " " + code[6:]}
+	for _, variant := range variants {
+		if HashRecoveryCode(variant) != HashRecoveryCode(code) {
+			t.Errorf("'%s' should hash the same as '%s'", variant, code)
+		}
+	}
+
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWebhookVerifier(t *testing.T) {
+
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	sent := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(sent.Unix(), 10)
+
+	verifier, err := NewWebhookVerifier(5*time.Minute, "current", "previous")
+	if err != nil {
+		t.Fatalf("error in creating verifier: %v", err)
+	}
+
+	current := SignWebhook("current", sent, body)
+	previous := SignWebhook("previous", sent, body)
+	unknown := SignWebhook("unknown", sent, body)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		at        time.Time
+		wantErr   bool
+	}{
+		{name: "Current Secret", timestamp: timestamp, signature: current, body: body, at: sent},
+		{name: "Previous Secret", timestamp: timestamp, signature: previous, body: body, at: sent},
+		{name: "Several Signatures", timestamp: timestamp, signature: unknown + ", " + current, body: body, at: sent},
+		{name: "Within Tolerance", timestamp: timestamp, signature: current, body: body, at: sent.Add(4 * time.Minute)},
+		{name: "Unknown Secret", timestamp: timestamp, signature: unknown, body: body, at: sent, wantErr: true},
+		{name: "Replayed Later", timestamp: timestamp, signature: current, body: body, at: sent.Add(6 * time.Minute), wantErr: true},
+		{name: "From The Future", timestamp: timestamp, signature: current, body: body, at: sent.Add(-6 * time.Minute), wantErr: true},
+		{name: "Body Changed", timestamp: timestamp, signature: current, body: []byte(`{}`), at: sent, wantErr: true},
+		{name: "Timestamp Changed", timestamp: "1700000001", signature: current, body: body, at: sent, wantErr: true},
+		{name: "Bad Timestamp", timestamp: "yesterday", signature: current, body: body, at: sent, wantErr: true},
+		{name: "Unknown Version", timestamp: timestamp, signature: "v0" + current[2:], body: body, at: sent, wantErr: true},
+		{name: "No Signature", timestamp: timestamp, signature: "", body: body, at: sent, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifier.Verify(test.timestamp, test.signature, test.body, test.at)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Verify error == %v, expected %v", err, test.wantErr)
+			}
+		})
+	}
+
+}
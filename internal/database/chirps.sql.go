@@ -7,36 +7,68 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const chirpHasReplies = `-- name: ChirpHasReplies :one
+SELECT EXISTS (SELECT 1 FROM chirps WHERE parent_id = $1)
+`
+
+func (q *Queries) ChirpHasReplies(ctx context.Context, parentID uuid.NullUUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpHasReplies, parentID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3)
+RETURNING
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body     string
+	UserID   uuid.UUID
+	ParentID uuid.NullUUID
 }
 
-func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
-	var i Chirp
+type CreateChirpRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (CreateChirpRow, error) {
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.ParentID)
+	var i CreateChirpRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.LikeCount,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1
+DELETE FROM chirps WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
@@ -44,43 +76,344 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    1 AS depth
+  FROM chirps c
+  WHERE c.id = (SELECT p.parent_id FROM chirps p WHERE p.id = $1)
+  UNION ALL
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    a.depth + 1
+  FROM chirps c
+  JOIN ancestors a ON c.id = a.parent_id
+)
+SELECT * FROM ancestors
+ORDER BY depth DESC
+`
+
+type GetChirpAncestorsRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+	Depth     int32
+}
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]GetChirpAncestorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpAncestorsRow
+	for rows.Next() {
+		var i GetChirpAncestorsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps WHERE id = $1 AND deleted_at IS NULL
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+type GetChirpByIDRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (GetChirpByIDRow, error) {
 	row := q.db.QueryRowContext(ctx, getChirpByID, id)
-	var i Chirp
+	var i GetChirpByIDRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.LikeCount,
 	)
 	return i, err
 }
 
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    1 AS depth
+  FROM chirps c
+  WHERE c.parent_id = $1
+  UNION ALL
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    d.depth + 1
+  FROM chirps c
+  JOIN descendants d ON c.parent_id = d.id
+)
+SELECT * FROM descendants
+ORDER BY depth, created_at, id
+`
+
+type GetChirpDescendantsRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+	Depth     int32
+}
+
+func (q *Queries) GetChirpDescendants(ctx context.Context, parentID uuid.NullUUID) ([]GetChirpDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpDescendantsRow
+	for rows.Next() {
+		var i GetChirpDescendantsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
+type GetChirpsRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) GetChirps(ctx context.Context) ([]GetChirpsRow, error) {
 	rows, err := q.db.QueryContext(ctx, getChirps)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []GetChirpsRow
+	for rows.Next() {
+		var i GetChirpsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsAfter = `-- name: GetChirpsAfter :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE deleted_at IS NULL
+  AND (created_at, id) > (
+    $1::timestamp, $2::uuid
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $3
+`
+
+type GetChirpsAfterParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageLimit       int32
+}
+
+type GetChirpsAfterRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) GetChirpsAfter(ctx context.Context, arg GetChirpsAfterParams) ([]GetChirpsAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsAfter, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsAfterRow
 	for rows.Next() {
-		var i Chirp
+		var i GetChirpsAfterRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsBefore = `-- name: GetChirpsBefore :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE deleted_at IS NULL
+  AND (created_at, id) < (
+    $1::timestamp, $2::uuid
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $3
+`
+
+type GetChirpsBeforeParams struct {
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageLimit       int32
+}
+
+type GetChirpsBeforeRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) GetChirpsBefore(ctx context.Context, arg GetChirpsBeforeParams) ([]GetChirpsBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsBefore, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsBeforeRow
+	for rows.Next() {
+		var i GetChirpsBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
 		); err != nil {
 			return nil, err
 		}
@@ -96,26 +429,276 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
 ORDER BY created_at
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+type GetChirpsByAuthorRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]GetChirpsByAuthorRow, error) {
 	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []GetChirpsByAuthorRow
+	for rows.Next() {
+		var i GetChirpsByAuthorRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByAuthorAfter = `-- name: GetChirpsByAuthorAfter :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND (created_at, id) > (
+    $2::timestamp, $3::uuid
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetChirpsByAuthorAfterParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageLimit       int32
+}
+
+type GetChirpsByAuthorAfterRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) GetChirpsByAuthorAfter(ctx context.Context, arg GetChirpsByAuthorAfterParams) ([]GetChirpsByAuthorAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorAfter,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsByAuthorAfterRow
+	for rows.Next() {
+		var i GetChirpsByAuthorAfterRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByAuthorBefore = `-- name: GetChirpsByAuthorBefore :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND (created_at, id) < (
+    $2::timestamp, $3::uuid
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetChirpsByAuthorBeforeParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt time.Time
+	CursorID        uuid.UUID
+	PageLimit       int32
+}
+
+type GetChirpsByAuthorBeforeRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) GetChirpsByAuthorBefore(ctx context.Context, arg GetChirpsByAuthorBeforeParams) ([]GetChirpsByAuthorBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorBefore,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsByAuthorBeforeRow
+	for rows.Next() {
+		var i GetChirpsByAuthorBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT
+  chirps.id,
+  chirps.created_at,
+  chirps.updated_at,
+  chirps.body,
+  chirps.user_id,
+  chirps.edited_at,
+  chirps.parent_id,
+  chirps.like_count,
+  ts_rank_cd(chirps.search_vector, tsq)::real AS rank,
+  ts_headline(
+    'english', chirps.body, tsq, $1::text
+  )::text AS snippet
+FROM chirps, to_tsquery('english', $2) tsq
+WHERE chirps.search_vector @@ tsq
+  AND chirps.deleted_at IS NULL
+  AND (
+    $3::uuid IS NULL OR chirps.user_id = $3
+  )
+ORDER BY
+  CASE WHEN $4::text = 'asc' THEN chirps.created_at END ASC,
+  CASE WHEN $4::text = 'desc' THEN chirps.created_at END DESC,
+  rank DESC,
+  chirps.id
+LIMIT $5
+`
+
+type SearchChirpsParams struct {
+	HeadlineOptions string
+	Query           string
+	AuthorID        uuid.NullUUID
+	Sort            string
+	PageLimit       int32
+}
+
+type SearchChirpsRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	LikeCount int32
+	Rank      float32
+	Snippet   string
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.HeadlineOptions,
+		arg.Query,
+		arg.AuthorID,
+		arg.Sort,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
 	for rows.Next() {
-		var i Chirp
+		var i SearchChirpsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.ParentID,
+			&i.LikeCount,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -129,3 +712,104 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const tombstoneChirp = `-- name: TombstoneChirp :one
+WITH scrubbed_revisions AS (
+  DELETE FROM chirp_revisions WHERE chirp_id = $1
+), scrubbed_hashtags AS (
+  DELETE FROM chirp_hashtags WHERE chirp_id = $1
+), scrubbed_mentions AS (
+  DELETE FROM chirp_mentions WHERE chirp_id = $1
+)
+UPDATE chirps
+SET
+  body = '',
+  deleted_at = NOW(),
+  updated_at = NOW()
+WHERE id = $1
+RETURNING
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+`
+
+type TombstoneChirpRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) (TombstoneChirpRow, error) {
+	row := q.db.QueryRowContext(ctx, tombstoneChirp, id)
+	var i TombstoneChirpRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+WITH revision AS (
+  INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+  SELECT gen_random_uuid(), id, body, COALESCE(edited_at, created_at), NOW()
+  FROM chirps
+  WHERE id = $1
+  FOR UPDATE
+)
+UPDATE chirps
+SET
+  body = $2,
+  edited_at = NOW(),
+  updated_at = NOW()
+WHERE id = $1
+RETURNING
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+type UpdateChirpBodyRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	EditedAt  sql.NullTime
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	LikeCount int32
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (UpdateChirpBodyRow, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i UpdateChirpBodyRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.LikeCount,
+	)
+	return i, err
+}
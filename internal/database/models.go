@@ -6,26 +6,122 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	SearchVector interface{}
+	EditedAt     sql.NullTime
+	ParentID     uuid.NullUUID
+	DeletedAt    sql.NullTime
+	LikeCount    int32
+}
+
+type ChirpFlag struct {
 	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Terms     []string
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	HashtagID uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+	Mention string
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type EmailVerification struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type MfaRecoveryCode struct {
 	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PasswordReset struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
-	Token     string
+	TokenHash string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+	Scopes    []string
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	PublishAt time.Time
+	CreatedAt time.Time
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	CanceledAt       sql.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 type User struct {
@@ -35,4 +131,61 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	EmailVerified  bool
+	PendingEmail   sql.NullString
+}
+
+type UserMfa struct {
+	UserID       uuid.UUID
+	Secret       string
+	EnabledAt    sql.NullTime
+	LastUsedStep int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	LastStatusCode sql.NullInt32
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          uuid.UUID
+	DeliveryID  uuid.UUID
+	AttemptedAt time.Time
+	StatusCode  sql.NullInt32
+	Error       string
+	DurationMs  int32
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+	Active     bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type WebhookEvent struct {
+	ID          string
+	EventType   string
+	Payload     json.RawMessage
+	ReceivedAt  time.Time
+	Outcome     string
+	Detail      string
+	Attempts    int32
+	ProcessedAt sql.NullTime
+	ClaimedAt   time.Time
 }
@@ -11,67 +11,89 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (
-  token, 
+  token_hash, 
   created_at, 
   updated_at, 
   user_id, 
-  expires_at
+  expires_at,
+  scopes
 )
-VALUES ($1, NOW(), NOW(), $2, NOW() + interval '60 days')
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+VALUES (
+  $1,
+  NOW(),
+  NOW(),
+  $2,
+  NOW() + make_interval(secs => $3::float8),
+  $4
+)
+RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
-	Token  string
-	UserID uuid.UUID
+	TokenHash       string
+	UserID          uuid.UUID
+	LifetimeSeconds float64
+	Scopes          []string
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.TokenHash,
+		arg.UserID,
+		arg.LifetimeSeconds,
+		pq.Array(arg.Scopes),
+	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
 SELECT 
-  token, created_at, updated_at, user_id, expires_at, revoked_at,
+  token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, scopes,
   expires_at < NOW() as is_expired
 FROM refresh_tokens 
-WHERE token = $1
+WHERE token_hash = $1
 `
 
 type GetRefreshTokenRow struct {
-	Token     string
+	TokenHash string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+	Scopes    []string
 	IsExpired bool
 }
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (GetRefreshTokenRow, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (GetRefreshTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
 	var i GetRefreshTokenRow
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		pq.Array(&i.Scopes),
 		&i.IsExpired,
 	)
 	return i, err
@@ -80,10 +102,90 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (GetRefresh
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1
+WHERE token_hash = $1
 `
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
+func (q *Queries) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshToken, tokenHash)
 	return err
 }
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :one
+WITH rotated AS (
+  UPDATE refresh_tokens
+  SET revoked_at = NOW(), updated_at = NOW()
+  WHERE token_hash = $1
+    AND revoked_at IS NULL
+    AND expires_at > NOW()
+  RETURNING user_id, family_id, scopes
+)
+INSERT INTO refresh_tokens (
+  token_hash,
+  created_at,
+  updated_at,
+  user_id,
+  expires_at,
+  family_id,
+  scopes
+)
+SELECT
+  $2::text,
+  NOW(),
+  NOW(),
+  rotated.user_id,
+  NOW() + make_interval(secs => $3::float8),
+  rotated.family_id,
+  rotated.scopes
+FROM rotated
+RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, scopes
+`
+
+type RotateRefreshTokenParams struct {
+	OldTokenHash    string
+	NewTokenHash    string
+	LifetimeSeconds float64
+}
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, rotateRefreshToken, arg.OldTokenHash, arg.NewTokenHash, arg.LifetimeSeconds)
+	var i RefreshToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -29,7 +30,7 @@ INSERT INTO users (
   hashed_password
 )
 VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified, pending_email
 `
 
 type CreateUserParams struct {
@@ -47,12 +48,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerified,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified, pending_email FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -65,6 +68,28 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerified,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified, pending_email FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerified,
+		&i.PendingEmail,
 	)
 	return i, err
 }
@@ -75,7 +100,7 @@ SET
   is_chirpy_red = $2,
   updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified, pending_email
 `
 
 type UpdateChirpyRedStatusParams struct {
@@ -93,6 +118,8 @@ func (q *Queries) UpdateChirpyRedStatus(ctx context.Context, arg UpdateChirpyRed
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerified,
+		&i.PendingEmail,
 	)
 	return i, err
 }
@@ -100,21 +127,77 @@ func (q *Queries) UpdateChirpyRedStatus(ctx context.Context, arg UpdateChirpyRed
 const updateUserInfo = `-- name: UpdateUserInfo :one
 UPDATE users
 SET 
-  email = $1,
+  pending_email = $1,
   hashed_password = $2,
   updated_at = NOW()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified, pending_email
 `
 
 type UpdateUserInfoParams struct {
-	Email          string
+	PendingEmail   sql.NullString
 	HashedPassword string
 	ID             uuid.UUID
 }
 
 func (q *Queries) UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserInfo, arg.Email, arg.HashedPassword, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateUserInfo, arg.PendingEmail, arg.HashedPassword, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerified,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+  hashed_password = $2,
+  updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET
+  email = $1,
+  email_verified = true,
+  pending_email = CASE
+    WHEN pending_email = $1 THEN NULL
+    ELSE pending_email
+  END,
+  updated_at = NOW()
+WHERE id = $2
+  AND (email = $1 OR pending_email = $1)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified, pending_email
+`
+
+type VerifyUserEmailParams struct {
+	Email string
+	ID    uuid.UUID
+}
+
+// only applies while the address is still the current or pending one, so a
+// stale link can't undo a later change
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.Email, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -123,6 +206,8 @@ func (q *Queries) UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams)
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerified,
+		&i.PendingEmail,
 	)
 	return i, err
 }
@@ -0,0 +1,37 @@
+package email
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// maximum forward-path length allowed by RFC 5321
+const maxAddressLength = 254
+
+// ValidateAddress accepts a bare address such as "user@example.com",
+// returning it with surrounding whitespace removed. Display names, comments
+// and domains without a dot are rejected since those aren't what a user
+// means to register with.
+func ValidateAddress(raw string) (string, error) {
+
+	addr := strings.TrimSpace(raw)
+	if addr == "" {
+		return "", errors.New("email address is required")
+	}
+	if len(addr) > maxAddressLength {
+		return "", errors.New("email address is too long")
+	}
+
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil || parsed.Name != "" || parsed.Address != addr {
+		return "", errors.New("invalid email address")
+	}
+
+	domain := addr[strings.LastIndex(addr, "@")+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", errors.New("invalid email address domain")
+	}
+
+	return addr, nil
+}
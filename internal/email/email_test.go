@@ -0,0 +1,170 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		msg      Message
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name: "Plain Message",
+			msg:  Message{To: "user@example.com", Subject: "Hello", Body: "line one\nline two"},
+			contains: []string{
+				"From: Chirpy <no-reply@chirpy.local>\r\n",
+				"To: user@example.com\r\n",
+				"Subject: Hello\r\n",
+				"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+				"\r\n\r\nline one\r\nline two",
+			},
+		},
+		{
+			name:     "Encoded Subject",
+			msg:      Message{To: "user@example.com", Subject: "Réinitialiser", Body: "hi"},
+			contains: []string{"Subject: =?utf-8?q?R=C3=A9initialiser?=\r\n"},
+		},
+		{
+			name:    "Header Injection",
+			msg:     Message{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi"},
+			wantErr: true,
+		},
+		{
+			name:    "No Recipient",
+			msg:     Message{Subject: "Hi"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := format("Chirpy <no-reply@chirpy.local>", test.msg, date)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("format error == %v, expected %v", err, test.wantErr)
+			}
+			for _, want := range test.contains {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("expected message to contain %q:\n%s", want, data)
+				}
+			}
+		})
+	}
+
+}
+
+func TestSpoolSender(t *testing.T) {
+
+	dir := filepath.Join(t.TempDir(), "spool")
+	sender := NewSpoolSender(dir, "no-reply@chirpy.local")
+
+	for i := 0; i < 2; i++ {
+		err := sender.Send(context.Background(), Message{To: "user@example.com", Subject: "Hi", Body: "body"})
+		if err != nil {
+			t.Fatalf("error in spooling email: %v", err)
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("error in reading spool: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 spooled emails, actual: %d", len(files))
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if !strings.Contains(string(data), "To: user@example.com\r\n") {
+		t.Errorf("spooled email missing recipient:\n%s", data)
+	}
+
+}
+
+func TestValidateAddress(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Valid", raw: "user@example.com", expected: "user@example.com"},
+		{name: "Plus And Subdomain", raw: "user+chirpy@mail.example.co.uk", expected: "user+chirpy@mail.example.co.uk"},
+		{name: "Whitespace Trimmed", raw: "  user@example.com\n", expected: "user@example.com"},
+		{name: "Empty", raw: "", wantErr: true},
+		{name: "No At", raw: "user.example.com", wantErr: true},
+		{name: "Display Name", raw: "User <user@example.com>", wantErr: true},
+		{name: "No Domain Dot", raw: "user@localhost", wantErr: true},
+		{name: "Trailing Dot", raw: "user@example.com.", wantErr: true},
+		{name: "Spaces Inside", raw: "us er@example.com", wantErr: true},
+		{name: "Too Long", raw: strings.Repeat("a", 250) + "@example.com", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr, err := ValidateAddress(test.raw)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ValidateAddress error == %v, expected %v", err, test.wantErr)
+			}
+			if addr != test.expected {
+				t.Errorf("expected: '%s', actual: '%s'", test.expected, addr)
+			}
+		})
+	}
+
+}
+
+// blockingSender holds every send until released, recording what it sent
+type blockingSender struct {
+	release chan struct{}
+	sent    chan Message
+}
+
+func (s *blockingSender) Send(ctx context.Context, msg Message) error {
+	<-s.release
+	s.sent <- msg
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+
+	sender := &blockingSender{release: make(chan struct{}), sent: make(chan Message, 2)}
+	queue := NewQueue(sender, 1, 1, time.Second, nil)
+	msg := Message{To: "user@example.com", Subject: "Hi"}
+
+	// the first message is taken by the worker and the second waits in the
+	// backlog, leaving no room for a third
+	if err := queue.Enqueue(msg); err != nil {
+		t.Fatalf("error in queueing email: %v", err)
+	}
+	for len(queue.pending) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := queue.Enqueue(msg); err != nil {
+		t.Fatalf("error in queueing email: %v", err)
+	}
+	if err := queue.Enqueue(msg); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, actual: %v", err)
+	}
+
+	close(sender.release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sender.sent:
+		case <-time.After(time.Second):
+			t.Fatalf("expected 2 sent emails, actual: %d", i)
+		}
+	}
+
+}
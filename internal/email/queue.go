@@ -0,0 +1,60 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrQueueFull is returned when every worker is busy and the backlog is at
+// capacity
+var ErrQueueFull = errors.New("email queue is full")
+
+// Queue sends messages in the background from a fixed pool of workers, so a
+// burst of requests can't start an unbounded number of sends. Messages that
+// fail to send are passed to onError rather than retried.
+type Queue struct {
+	sender  Sender
+	timeout time.Duration
+	onError func(Message, error)
+	pending chan Message
+}
+
+// NewQueue starts workers sending through sender, each send limited to
+// timeout, with up to backlog messages waiting for a free worker
+func NewQueue(sender Sender, workers, backlog int, timeout time.Duration, onError func(Message, error)) *Queue {
+
+	q := &Queue{
+		sender:  sender,
+		timeout: timeout,
+		onError: onError,
+		pending: make(chan Message, backlog),
+	}
+	for range workers {
+		go q.work()
+	}
+
+	return q
+}
+
+// Enqueue hands msg to the workers without waiting for it to be sent
+func (q *Queue) Enqueue(msg Message) error {
+
+	select {
+	case q.pending <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) work() {
+
+	for msg := range q.pending {
+		ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+		if err := q.sender.Send(ctx, msg); err != nil && q.onError != nil {
+			q.onError(msg, err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,48 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPSender relays through an SMTP server without authentication, such as
+// a local MTA or a development catcher like MailHog
+type SMTPSender struct {
+	Addr string
+	From string
+}
+
+func NewSMTPSender(addr, from string) *SMTPSender {
+	return &SMTPSender{Addr: addr, From: from}
+}
+
+// Send doesn't honour ctx cancellation once the SMTP conversation starts
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := format(s.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	if err := smtp.SendMail(s.Addr, nil, from.Address, []string{to.Address}, data); err != nil {
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SpoolSender writes each message to its own .eml file instead of
+// delivering it, for development and tests
+type SpoolSender struct {
+	Dir  string
+	From string
+}
+
+func NewSpoolSender(dir, from string) *SpoolSender {
+	return &SpoolSender{Dir: dir, From: from}
+}
+
+func (s *SpoolSender) Send(ctx context.Context, msg Message) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	data, err := format(s.From, msg, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("unable to create spool directory: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("unable to name spooled email: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	if err := os.WriteFile(filepath.Join(s.Dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("unable to spool email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Users are identified by email, so a mention is an @ followed by an address:
+// "thanks @alice@example.com!"
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]*\p{L}[\p{L}\p{N}_]*)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+)
+
+type Entities struct {
+	// Hashtags without the leading #, normalized with NormalizeHashtag
+	Hashtags []string
+	// Mentions as lower cased email addresses
+	Mentions []string
+}
+
+// Parse extracts the unique hashtags and mentions from a chirp body in the
+// order they first appear
+func Parse(body string) Entities {
+	return Entities{
+		Hashtags: unique(hashtagPattern, body, NormalizeHashtag),
+		Mentions: unique(mentionPattern, body, strings.ToLower),
+	}
+}
+
+// NormalizeHashtag converts a tag to the form it is stored and looked up by
+func NormalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+func unique(pattern *regexp.Regexp, body string, normalize func(string) string) []string {
+
+	values := []string{}
+	seen := map[string]bool{}
+	for _, match := range pattern.FindAllStringSubmatch(body, -1) {
+		value := normalize(match[1])
+		if seen[value] {
+			continue
+		}
+
+		seen[value] = true
+		values = append(values, value)
+	}
+
+	return values
+}
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		body     string
+		hashtags []string
+		mentions []string
+	}{
+		{
+			name:     "None",
+			body:     "just a regular chirp",
+			hashtags: []string{},
+			mentions: []string{},
+		},
+		{
+			name:     "Hashtags",
+			body:     "#Go is great, #golang #go_lang! #Go",
+			hashtags: []string{"go", "golang", "go_lang"},
+			mentions: []string{},
+		},
+		{
+			name:     "Unicode Hashtag",
+			body:     "vive le #Québec",
+			hashtags: []string{"québec"},
+			mentions: []string{},
+		},
+		{
+			name:     "Not Hashtags",
+			body:     "issue#42 is #1, &#39; and ##double",
+			hashtags: []string{},
+			mentions: []string{},
+		},
+		{
+			name:     "Mentions",
+			body:     "@Alice@Example.com and (@bob@example.co.uk). cc @alice@example.com",
+			hashtags: []string{},
+			mentions: []string{"alice@example.com", "bob@example.co.uk"},
+		},
+		{
+			name:     "Not Mentions",
+			body:     "mail me at carol@example.com or @dave",
+			hashtags: []string{},
+			mentions: []string{},
+		},
+		{
+			name:     "Mixed",
+			body:     "@erin@example.com check out #chirpy",
+			hashtags: []string{"chirpy"},
+			mentions: []string{"erin@example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Parse(test.body)
+			if !slices.Equal(got.Hashtags, test.hashtags) {
+				t.Errorf("expected hashtags: %v, actual: %v", test.hashtags, got.Hashtags)
+			}
+			if !slices.Equal(got.Mentions, test.mentions) {
+				t.Errorf("expected mentions: %v, actual: %v", test.mentions, got.Mentions)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,155 @@
+package entitlements
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Tier string
+
+const (
+	// TierFree - every account
+	TierFree Tier = "free"
+	// TierRed - accounts with an active Chirpy Red subscription
+	TierRed Tier = "red"
+)
+
+// Perks are the limits and features a tier gets. Handlers ask for the perks
+// of the user making the request rather than checking their tier themselves.
+type Perks struct {
+	MaxChirpLength int  `json:"max_chirp_length"`
+	EditChirps     bool `json:"edit_chirps"`
+	// ChirpsPerHour limits how many chirps can be posted in any hour; zero
+	// means no limit
+	ChirpsPerHour  int  `json:"chirps_per_hour"`
+	ScheduleChirps bool `json:"schedule_chirps"`
+}
+
+type Matrix map[Tier]Perks
+
+// DefaultMatrix is used when no entitlements file is configured, and is the
+// starting point that a file overrides
+func DefaultMatrix() Matrix {
+	return Matrix{
+		TierFree: {
+			MaxChirpLength: 140,
+			EditChirps:     false,
+			ChirpsPerHour:  30,
+			ScheduleChirps: false,
+		},
+		TierRed: {
+			MaxChirpLength: 280,
+			EditChirps:     true,
+			ChirpsPerHour:  300,
+			ScheduleChirps: true,
+		},
+	}
+}
+
+func TierFor(isChirpyRed bool) Tier {
+	if isChirpyRed {
+		return TierRed
+	}
+	return TierFree
+}
+
+func (m Matrix) For(tier Tier) Perks {
+	return m[tier]
+}
+
+func LoadMatrix(path string) (Matrix, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open entitlements file: %w", err)
+	}
+	defer f.Close()
+
+	return ParseMatrix(f)
+}
+
+// ParseMatrix reads perk overrides with one per line in the form
+//
+//	<tier> <perk> <value>
+//
+// where tier is free or red and perk is one of max_chirp_length,
+// edit_chirps, chirps_per_hour or schedule_chirps. Perks that aren't
+// mentioned keep their defaults. Blank lines and lines starting with # are
+// ignored.
+func ParseMatrix(r io.Reader) (Matrix, error) {
+
+	matrix := DefaultMatrix()
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected '<tier> <perk> <value>', got %q", lineNum, line)
+		}
+
+		tier := Tier(strings.ToLower(fields[0]))
+		perks, ok := matrix[tier]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown tier %q", lineNum, fields[0])
+		}
+
+		if err := perks.set(strings.ToLower(fields[1]), fields[2]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		matrix[tier] = perks
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read entitlements file: %w", err)
+	}
+
+	return matrix, nil
+}
+
+func (p *Perks) set(perk, value string) error {
+
+	switch perk {
+	case "max_chirp_length":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("max_chirp_length must be a positive number, got %q", value)
+		}
+		p.MaxChirpLength = n
+
+	case "chirps_per_hour":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("chirps_per_hour must be zero or more, got %q", value)
+		}
+		p.ChirpsPerHour = n
+
+	case "edit_chirps", "schedule_chirps":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", perk, value)
+		}
+		if perk == "edit_chirps" {
+			p.EditChirps = enabled
+		} else {
+			p.ScheduleChirps = enabled
+		}
+
+	default:
+		return fmt.Errorf("unknown perk %q", perk)
+	}
+
+	return nil
+}
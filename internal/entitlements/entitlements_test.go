@@ -0,0 +1,93 @@
+package entitlements
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMatrix(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		list    string
+		tier    Tier
+		perks   Perks
+		wantErr bool
+	}{
+		{
+			name:  "Empty Keeps Defaults",
+			list:  "# nothing to change\n\n",
+			tier:  TierFree,
+			perks: DefaultMatrix()[TierFree],
+		},
+		{
+			name: "Overrides",
+			list: "red max_chirp_length 500\nRED chirps_per_hour 0\nfree edit_chirps true",
+			tier: TierRed,
+			perks: Perks{
+				MaxChirpLength: 500,
+				EditChirps:     true,
+				ChirpsPerHour:  0,
+				ScheduleChirps: true,
+			},
+		},
+		{
+			name:    "Free Tier Override",
+			list:    "free schedule_chirps yes",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown Tier",
+			list:    "gold max_chirp_length 1000",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown Perk",
+			list:    "red polls true",
+			wantErr: true,
+		},
+		{
+			name:    "Zero Length",
+			list:    "free max_chirp_length 0",
+			wantErr: true,
+		},
+		{
+			name:    "Missing Value",
+			list:    "red edit_chirps",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matrix, err := ParseMatrix(strings.NewReader(test.list))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseMatrix error == %v, expected %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := matrix.For(test.tier); got != test.perks {
+				t.Errorf("expected: %+v, actual: %+v", test.perks, got)
+			}
+		})
+	}
+
+}
+
+func TestTierFor(t *testing.T) {
+
+	matrix := DefaultMatrix()
+
+	red := matrix.For(TierFor(true))
+	free := matrix.For(TierFor(false))
+
+	if red.MaxChirpLength <= free.MaxChirpLength || red.ChirpsPerHour <= free.ChirpsPerHour {
+		t.Errorf("red should have higher limits than free: red %+v, free %+v", red, free)
+	}
+	if !red.EditChirps || free.EditChirps {
+		t.Errorf("only red should be able to edit chirps: red %+v, free %+v", red, free)
+	}
+
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+)
+
+// sqlc prefixes every query with "-- name: QueryName :kind"
+var queryNamePattern = regexp.MustCompile(`^-- name: (\w+)`)
+
+type instrumentedDB struct {
+	db      database.DBTX
+	metrics *Metrics
+}
+
+// InstrumentDB wraps a connection so the duration of every query run through
+// database.Queries is recorded under its sqlc query name
+func (m *Metrics) InstrumentDB(db database.DBTX) database.DBTX {
+	return &instrumentedDB{db: db, metrics: m}
+}
+
+func (i *instrumentedDB) observe(query string, start time.Time, err error) {
+
+	name := "unknown"
+	if match := queryNamePattern.FindStringSubmatch(query); match != nil {
+		name = match[1]
+	}
+
+	outcome := "ok"
+	if err != nil && err != sql.ErrNoRows {
+		outcome = "error"
+	}
+
+	i.metrics.queryDuration.WithLabelValues(name, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (i *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := i.db.ExecContext(ctx, query, args...)
+	i.observe(query, start, err)
+	return result, err
+}
+
+func (i *instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+// QueryContext times the query up to its first result; rows are streamed
+// afterwards by the caller
+func (i *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	i.observe(query, start, err)
+	return rows, err
+}
+
+// QueryRowContext errors are deferred until Scan, so these are always
+// recorded as ok
+func (i *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := i.db.QueryRowContext(ctx, query, args...)
+	i.observe(query, start, row.Err())
+	return row
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chirpy"
+
+// UnmatchedRoute labels requests that didn't match any registered pattern, so
+// scanners probing random paths can't blow up label cardinality
+const UnmatchedRoute = "unmatched"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        *prometheus.GaugeVec
+	queryDuration   *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "http_requests_total",
+				Help:      "HTTP requests handled, by route pattern and status code.",
+			},
+			[]string{"method", "route", "status"},
+		),
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "http_request_duration_seconds",
+				Help:      "Time taken to handle HTTP requests, by route pattern and status code.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"method", "route", "status"},
+		),
+		inFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "http_requests_in_flight",
+				Help:      "HTTP requests currently being handled, by route pattern.",
+			},
+			[]string{"route"},
+		),
+		queryDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "db_query_duration_seconds",
+				Help:      "Time taken by database queries, by sqlc query name and outcome.",
+				Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+			},
+			[]string{"query", "outcome"},
+		),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.queryDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler serves the collected metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RequestCount totals the requests served for a route pattern across all
+// methods and status codes
+func (m *Metrics) RequestCount(route string) (float64, error) {
+
+	families, err := m.registry.Gather()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, family := range families {
+		if family.GetName() != namespace+"_http_requests_total" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "route" && label.GetValue() == route {
+					total += metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// ResetRequests clears the HTTP request metrics
+func (m *Metrics) ResetRequests() {
+	m.requests.Reset()
+	m.requestDuration.Reset()
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	m := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /api/healthz", func(resp http.ResponseWriter, req *http.Request) {
+		resp.Write([]byte("OK"))
+	})
+
+	handler := m.Middleware(mux)
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/api/healthz", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	tests := []struct {
+		route string
+		count float64
+	}{
+		{route: "GET /api/chirps/{chirpID}", count: 2},
+		{route: "GET /api/healthz", count: 1},
+		{route: UnmatchedRoute, count: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.route, func(t *testing.T) {
+			count, err := m.RequestCount(test.route)
+			if err != nil {
+				t.Fatalf("unable to gather metrics: %v", err)
+			}
+			if count != test.count {
+				t.Errorf("expected %v requests, actual: %v", test.count, count)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	expected := []string{
+		`chirpy_http_requests_total{method="GET",route="GET /api/chirps/{chirpID}",status="404"} 2`,
+		`chirpy_http_requests_total{method="GET",route="GET /api/healthz",status="200"} 1`,
+		`chirpy_http_request_duration_seconds_bucket{method="GET",route="GET /api/healthz",status="200",le="+Inf"} 1`,
+		`chirpy_http_requests_in_flight{route="GET /api/healthz"} 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("exposition missing line: %s", line)
+		}
+	}
+
+	m.ResetRequests()
+	if count, _ := m.RequestCount("GET /api/healthz"); count != 0 {
+		t.Errorf("expected reset count to be 0, actual: %v", count)
+	}
+
+}
+
+func TestQueryName(t *testing.T) {
+
+	tests := []struct {
+		query string
+		name  string
+	}{
+		{query: "-- name: GetChirpByID :one\nSELECT 1", name: "GetChirpByID"},
+		{query: "SELECT 1", name: ""},
+	}
+
+	for _, test := range tests {
+		match := queryNamePattern.FindStringSubmatch(test.query)
+		got := ""
+		if match != nil {
+			got = match[1]
+		}
+		if got != test.name {
+			t.Errorf("expected: '%s', actual: '%s'", test.name, got)
+		}
+	}
+
+}
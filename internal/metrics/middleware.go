@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware records request metrics for every request routed by mux. The
+// route label is the pattern the request matched (e.g.
+// "GET /api/chirps/{chirpID}") rather than the raw path.
+func (m *Metrics) Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+
+		route := UnmatchedRoute
+		if _, pattern := mux.Handler(req); pattern != "" {
+			route = pattern
+		}
+
+		inFlight := m.inFlight.WithLabelValues(route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		recorder := &statusRecorder{ResponseWriter: resp, status: http.StatusOK}
+		start := time.Now()
+
+		mux.ServeHTTP(recorder, req)
+
+		status := strconv.Itoa(recorder.status)
+		m.requests.WithLabelValues(req.Method, route, status).Inc()
+		m.requestDuration.WithLabelValues(req.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach flush and deadline support on
+// the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack supports WebSocket upgrades, which type assert for http.Hijacker
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	// the connection is handed off; record it as a protocol switch
+	r.status = http.StatusSwitchingProtocols
+	r.wroteHeader = true
+
+	return hijacker.Hijack()
+}
@@ -0,0 +1,178 @@
+package moderation
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+
+	t.Run("Valid Word List", func(t *testing.T) {
+		rules, err := ParseRules(strings.NewReader(`
+# banned words
+mask kerfuffle
+REJECT Sharbert
+
+flag fórnax
+`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := []Rule{
+			{Term: "kerfuffle", Action: ActionMask},
+			{Term: "sharbert", Action: ActionReject},
+			{Term: "fornax", Action: ActionFlag},
+		}
+		if !slices.Equal(rules, want) {
+			t.Errorf("expected: %v, actual: %v", want, rules)
+		}
+	})
+
+	invalid := []struct {
+		name string
+		list string
+	}{
+		{name: "Unknown Action", list: "ban kerfuffle"},
+		{name: "Missing Term", list: "mask"},
+		{name: "Extra Fields", list: "mask two words"},
+		{name: "No Letters", list: "mask ???"},
+	}
+
+	for _, test := range invalid {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseRules(strings.NewReader(test.list))
+			if err == nil {
+				t.Error("expected an error parsing word list")
+			}
+		})
+	}
+
+}
+
+func TestModerate(t *testing.T) {
+
+	m := New([]Rule{
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionMask},
+		{Term: "fornax", Action: ActionReject},
+		{Term: "gizmo", Action: ActionFlag},
+		{Term: "gizmo", Action: ActionMask},
+	})
+
+	tests := []struct {
+		name     string
+		body     string
+		want     string
+		rejected bool
+		flagged  bool
+		matches  int
+	}{
+		{
+			name: "Clean",
+			body: "I had something interesting for breakfast",
+			want: "I had something interesting for breakfast",
+		},
+		{
+			name:    "Mask",
+			body:    "This is a kerfuffle opinion I need to share",
+			want:    "This is a **** opinion I need to share",
+			matches: 1,
+		},
+		{
+			name:    "Case Insensitive",
+			body:    "What a KerFuffle",
+			want:    "What a ****",
+			matches: 1,
+		},
+		{
+			name:    "Punctuation Kept",
+			body:    "What a kerfuffle! Truly, (sharbert).",
+			want:    "What a ****! Truly, (****).",
+			matches: 2,
+		},
+		{
+			name:    "Leetspeak",
+			body:    "k3rfuffl3 and $harb3rt",
+			want:    "**** and ****",
+			matches: 2,
+		},
+		{
+			name:    "Accents And Separators",
+			body:    "so much k.é.r.f.u.f.f.l.e",
+			want:    "so much ****",
+			matches: 1,
+		},
+		{
+			name:    "Whitespace Preserved",
+			body:    "  kerfuffle\tsharbert\n",
+			want:    "  ****\t****\n",
+			matches: 2,
+		},
+		{
+			name:     "Reject",
+			body:     "fornax is coming",
+			want:     "fornax is coming",
+			rejected: true,
+			matches:  1,
+		},
+		{
+			name:    "Strictest Rule Wins",
+			body:    "my gizmo",
+			want:    "my gizmo",
+			flagged: true,
+			matches: 1,
+		},
+		{
+			name: "Substring Not Matched",
+			body: "kerfuffles are fine",
+			want: "kerfuffles are fine",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := m.Moderate(test.body)
+			if result.Body != test.want {
+				t.Errorf("expected: '%s', actual: '%s'", test.want, result.Body)
+			}
+			if result.Rejected != test.rejected {
+				t.Errorf("rejected == %v, expected %v", result.Rejected, test.rejected)
+			}
+			if result.Flagged != test.flagged {
+				t.Errorf("flagged == %v, expected %v", result.Flagged, test.flagged)
+			}
+			if len(result.Matches) != test.matches {
+				t.Errorf("expected %d matches, actual: %v", test.matches, result.Matches)
+			}
+		})
+	}
+
+}
+
+func TestModerateLength(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		body   string
+		length int
+	}{
+		{name: "ASCII", body: "hello", length: 5},
+		{name: "Accented", body: "héllo", length: 5},
+		{name: "Combining Mark", body: "héllo", length: 5},
+		{name: "Emoji", body: "👍🏽🇨🇦", length: 2},
+		{name: "Family Emoji", body: "👨‍👩‍👧‍👦", length: 1},
+	}
+
+	m := New(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := m.Moderate(test.body)
+			if result.Length != test.length {
+				t.Errorf("expected length: %d, actual length: %d", test.length, result.Length)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,126 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+const maskText = "****"
+
+type Match struct {
+	Term   string `json:"term"`
+	Action Action `json:"action"`
+}
+
+type Result struct {
+	// Body with every masked word replaced
+	Body string
+	// Length of the submitted body in user perceived characters
+	Length   int
+	Rejected bool
+	Flagged  bool
+	Matches  []Match
+}
+
+type Moderator struct {
+	rules map[string]Action
+}
+
+// New builds a Moderator from rules. When a term appears more than once the
+// strictest action wins.
+func New(rules []Rule) *Moderator {
+
+	m := &Moderator{rules: make(map[string]Action, len(rules))}
+	for _, rule := range rules {
+		if severity(rule.Action) > severity(m.rules[rule.Term]) {
+			m.rules[rule.Term] = rule.Action
+		}
+	}
+
+	return m
+}
+
+func (m *Moderator) Moderate(body string) Result {
+
+	result := Result{Length: uniseg.GraphemeClusterCount(body)}
+
+	var sb strings.Builder
+	for len(body) > 0 {
+		// copy whitespace through untouched so masking doesn't reflow the text
+		wordStart := strings.IndexFunc(body, func(r rune) bool { return !unicode.IsSpace(r) })
+		if wordStart == -1 {
+			sb.WriteString(body)
+			break
+		}
+		sb.WriteString(body[:wordStart])
+		body = body[wordStart:]
+
+		wordEnd := strings.IndexFunc(body, unicode.IsSpace)
+		if wordEnd == -1 {
+			wordEnd = len(body)
+		}
+		word := body[:wordEnd]
+		body = body[wordEnd:]
+
+		match, masked, found := m.match(word)
+		if !found {
+			sb.WriteString(word)
+			continue
+		}
+
+		result.Matches = append(result.Matches, match)
+
+		switch match.Action {
+		case ActionMask:
+			sb.WriteString(masked)
+		case ActionReject:
+			result.Rejected = true
+			sb.WriteString(word)
+		case ActionFlag:
+			result.Flagged = true
+			sb.WriteString(word)
+		}
+	}
+
+	result.Body = sb.String()
+
+	return result
+}
+
+// match looks a word up in the rules and returns the text that should
+// replace it if masked. Punctuation around a word is usually just punctuation
+// ("kerfuffle!") and survives masking, but it may also be a substitution
+// ("$harbert"), so the whole word is checked as well.
+func (m *Moderator) match(word string) (Match, string, bool) {
+
+	prefix, core, suffix := splitWord(word)
+
+	term := normalize(core)
+	if action, ok := m.rules[term]; ok {
+		return Match{Term: term, Action: action}, prefix + maskText + suffix, true
+	}
+
+	if core != word {
+		term = normalize(word)
+		if action, ok := m.rules[term]; ok {
+			return Match{Term: term, Action: action}, maskText, true
+		}
+	}
+
+	return Match{}, "", false
+}
+
+func severity(action Action) int {
+	switch action {
+	case ActionReject:
+		return 3
+	case ActionFlag:
+		return 2
+	case ActionMask:
+		return 1
+	default:
+		return 0
+	}
+}
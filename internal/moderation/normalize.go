@@ -0,0 +1,62 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// common character substitutions used to dodge word filters
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'l',
+	'+': 't',
+}
+
+// normalize reduces a word to the lower case, unaccented letters it is meant
+// to read as, so "Kérf.u.f.f.l3" compares equal to "kerfuffle"
+func normalize(word string) string {
+
+	var sb strings.Builder
+	for _, r := range norm.NFKD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		if sub, ok := leetspeak[r]; ok {
+			r = sub
+		}
+
+		if unicode.IsLetter(r) {
+			sb.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	return sb.String()
+}
+
+// splitWord separates the leading and trailing punctuation from a word
+func splitWord(word string) (prefix, core, suffix string) {
+
+	isPunct := func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}
+
+	core = strings.TrimLeftFunc(word, isPunct)
+	prefix = word[:len(word)-len(core)]
+
+	core = strings.TrimRightFunc(core, isPunct)
+	suffix = word[len(prefix)+len(core):]
+
+	return prefix, core, suffix
+}
@@ -0,0 +1,92 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type Action string
+
+const (
+	// ActionMask - the offending word is replaced with asterisks
+	ActionMask Action = "mask"
+	// ActionReject - the chirp is refused
+	ActionReject Action = "reject"
+	// ActionFlag - the chirp is accepted but queued for human review
+	ActionFlag Action = "flag"
+)
+
+type Rule struct {
+	Term   string
+	Action Action
+}
+
+// DefaultRules is used when no word list is configured
+func DefaultRules() []Rule {
+	return []Rule{
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionMask},
+		{Term: "fornax", Action: ActionMask},
+	}
+}
+
+func LoadRules(path string) ([]Rule, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open word list: %w", err)
+	}
+	defer f.Close()
+
+	return ParseRules(f)
+}
+
+// ParseRules reads a word list with one rule per line in the form
+//
+//	<action> <term>
+//
+// where action is one of mask, reject or flag. Blank lines and lines starting
+// with # are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+
+	var rules []Rule
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected '<action> <term>', got %q", lineNum, line)
+		}
+
+		action := Action(strings.ToLower(fields[0]))
+		switch action {
+		case ActionMask, ActionReject, ActionFlag:
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q", lineNum, fields[0])
+		}
+
+		term := normalize(fields[1])
+		if term == "" {
+			return nil, fmt.Errorf("line %d: term %q has no letters", lineNum, fields[1])
+		}
+
+		rules = append(rules, Rule{Term: term, Action: action})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read word list: %w", err)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,86 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Direction string
+
+const (
+	// DirectionNext - page continues past the cursor in the requested sort order
+	DirectionNext Direction = "next"
+	// DirectionPrev - page ends just before the cursor in the requested sort order
+	DirectionPrev Direction = "prev"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Cursor marks a position in a listing ordered by (created_at, id). Clients
+// only ever see the encoded form and should treat it as opaque.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	// Key stands in for ID in listings whose rows have text keys
+	Key       string    `json:"k,omitempty"`
+	Direction Direction `json:"d"`
+}
+
+func (c Cursor) Encode() string {
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		// all fields have infallible encodings
+		panic(fmt.Sprintf("unable to encode cursor: %s", err))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(encoded string) (Cursor, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+
+	c := Cursor{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor contents: %w", err)
+	}
+
+	if c.Direction != DirectionNext && c.Direction != DirectionPrev {
+		return Cursor{}, fmt.Errorf("invalid cursor direction: %q", c.Direction)
+	}
+
+	return c, nil
+}
+
+// ParseLimit reads a page size from a query parameter, falling back to
+// DefaultLimit when it is absent and capping it at MaxLimit.
+func ParseLimit(raw string) (int, error) {
+
+	if raw == "" {
+		return DefaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit: %w", err)
+	}
+
+	if limit < 1 {
+		return 0, errors.New("limit must be positive")
+	}
+
+	return min(limit, MaxLimit), nil
+}
@@ -0,0 +1,98 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+
+	c := Cursor{
+		CreatedAt: time.Date(2024, 12, 23, 10, 4, 5, 123456000, time.UTC),
+		ID:        uuid.New(),
+		Key:       "evt_123",
+		Direction: DirectionPrev,
+	}
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("unable to decode cursor: %v", err)
+	}
+
+	if !decoded.CreatedAt.Equal(c.CreatedAt) || decoded.ID != c.ID || decoded.Key != c.Key || decoded.Direction != c.Direction {
+		t.Errorf("expected: %+v, actual: %+v", c, decoded)
+	}
+
+}
+
+func TestDecodeCursor(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cursor  string
+		wantErr bool
+	}{
+		{
+			name:    "Valid Cursor",
+			cursor:  Cursor{ID: uuid.New(), Direction: DirectionNext}.Encode(),
+			wantErr: false,
+		},
+		{
+			name:    "Not Base64",
+			cursor:  "not a cursor!",
+			wantErr: true,
+		},
+		{
+			name:    "Not JSON",
+			cursor:  "bm90IGpzb24",
+			wantErr: true,
+		},
+		{
+			name:    "Missing Direction",
+			cursor:  Cursor{ID: uuid.New()}.Encode(),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeCursor(test.cursor)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Decode cursor error == %v, expected %v", err, test.wantErr)
+			}
+		})
+	}
+
+}
+
+func TestParseLimit(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		raw     string
+		limit   int
+		wantErr bool
+	}{
+		{name: "Default", raw: "", limit: DefaultLimit},
+		{name: "Explicit", raw: "10", limit: 10},
+		{name: "Capped", raw: "100000", limit: MaxLimit},
+		{name: "Zero", raw: "0", wantErr: true},
+		{name: "Negative", raw: "-5", wantErr: true},
+		{name: "Not A Number", raw: "ten", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit, err := ParseLimit(test.raw)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Parse limit error == %v, expected %v", err, test.wantErr)
+			}
+			if limit != test.limit {
+				t.Errorf("expected limit: %d, actual limit: %d", test.limit, limit)
+			}
+		})
+	}
+
+}
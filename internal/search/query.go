@@ -0,0 +1,158 @@
+package search
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// ToTSQuery converts a user supplied search string into Postgres to_tsquery
+// syntax. All terms must match; the following operators are understood:
+//
+//	"two words"  phrase, the words must appear next to each other
+//	chir*        prefix match
+//	-word        exclude chirps containing word
+func ToTSQuery(query string) (string, error) {
+
+	var clauses []string
+	hasPositive := false
+
+	for _, tok := range tokenize(query) {
+
+		negate := strings.HasPrefix(tok.text, "-")
+		text := strings.TrimPrefix(tok.text, "-")
+
+		var clause string
+		if tok.phrase {
+			var words []string
+			for _, word := range strings.Fields(text) {
+				if w := sanitize(word); w != "" {
+					words = append(words, w)
+				}
+			}
+			if len(words) == 0 {
+				continue
+			}
+			clause = strings.Join(words, " <-> ")
+			if len(words) > 1 {
+				clause = "(" + clause + ")"
+			}
+		} else {
+			prefix := strings.HasSuffix(text, "*")
+			clause = sanitize(strings.TrimSuffix(text, "*"))
+			if clause == "" {
+				continue
+			}
+			if prefix {
+				clause += ":*"
+			}
+		}
+
+		if negate {
+			clause = "!" + clause
+		} else {
+			hasPositive = true
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	if !hasPositive {
+		return "", errors.New("search query must contain at least one term")
+	}
+
+	return strings.Join(clauses, " & "), nil
+}
+
+// Highlighter has ts_headline wrap matched terms in random delimiters, so
+// text typed into a chirp can never be mistaken for a highlight
+type Highlighter struct {
+	start string
+	stop  string
+}
+
+func NewHighlighter() (Highlighter, error) {
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return Highlighter{}, fmt.Errorf("error generating highlight delimiter: %w", err)
+	}
+
+	token := hex.EncodeToString(raw)
+	return Highlighter{start: "hlstart" + token, stop: "hlstop" + token}, nil
+}
+
+// HeadlineOptions is the options string to pass to ts_headline
+func (h Highlighter) HeadlineOptions() string {
+	return fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2", h.start, h.stop)
+}
+
+// EscapeSnippet HTML escapes a ts_headline snippet, then turns the
+// delimiters around matched terms into <mark> tags
+func (h Highlighter) EscapeSnippet(snippet string) string {
+
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, h.start, highlightStart)
+	escaped = strings.ReplaceAll(escaped, h.stop, highlightStop)
+
+	return escaped
+}
+
+type token struct {
+	text   string
+	phrase bool
+}
+
+func tokenize(query string) []token {
+
+	var tokens []token
+	for query != "" {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+
+		// a phrase may itself be negated: -"some words"
+		negate := ""
+		rest := query
+		if strings.HasPrefix(rest, "-") {
+			negate, rest = "-", rest[1:]
+		}
+
+		if strings.HasPrefix(rest, `"`) {
+			phrase, after, _ := strings.Cut(rest[1:], `"`)
+			tokens = append(tokens, token{text: negate + phrase, phrase: true})
+			query = after
+			continue
+		}
+
+		end := strings.IndexFunc(query, unicode.IsSpace)
+		if end == -1 {
+			end = len(query)
+		}
+		tokens = append(tokens, token{text: query[:end]})
+		query = query[end:]
+	}
+
+	return tokens
+}
+
+// sanitize strips everything but letters and digits so user input can never
+// inject tsquery operators
+func sanitize(word string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, word)
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToTSQuery(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Single Word", query: "chirpy", want: "chirpy"},
+		{name: "Multiple Words", query: "hello  World", want: "hello & world"},
+		{name: "Prefix", query: "chirp*", want: "chirp:*"},
+		{name: "Phrase", query: `"boot dev" rocks`, want: "(boot <-> dev) & rocks"},
+		{name: "Single Word Phrase", query: `"boot"`, want: "boot"},
+		{name: "Negation", query: "gopher -rust", want: "gopher & !rust"},
+		{name: "Negated Phrase", query: `gopher -"rust lang"`, want: "gopher & !(rust <-> lang)"},
+		{name: "Unterminated Phrase", query: `"boot dev`, want: "(boot <-> dev)"},
+		{name: "Operators Stripped", query: "a&b | !c:*", want: "ab & c:*"},
+		{name: "Only Negation", query: "-rust", wantErr: true},
+		{name: "Empty", query: "   ", wantErr: true},
+		{name: "Only Punctuation", query: "!!! &&", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ToTSQuery(test.query)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ToTSQuery error == %v, expected %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("expected: '%s', actual: '%s'", test.want, got)
+			}
+		})
+	}
+
+}
+
+func TestEscapeSnippet(t *testing.T) {
+
+	h := Highlighter{start: "[start]", stop: "[stop]"}
+
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{
+			name:    "Plain Text",
+			snippet: "nothing to see",
+			want:    "nothing to see",
+		},
+		{
+			name:    "Highlight Preserved",
+			snippet: "say [start]hello[stop] world",
+			want:    "say <mark>hello</mark> world",
+		},
+		{
+			name:    "Markup Escaped",
+			snippet: "<b>bold</b> [start]<i>x</i>[stop] & more",
+			want:    "&lt;b&gt;bold&lt;/b&gt; <mark>&lt;i&gt;x&lt;/i&gt;</mark> &amp; more",
+		},
+		{
+			name:    "Typed Mark Escaped",
+			snippet: "<mark>fake</mark> [start]real[stop]",
+			want:    "&lt;mark&gt;fake&lt;/mark&gt; <mark>real</mark>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := h.EscapeSnippet(test.snippet)
+			if got != test.want {
+				t.Errorf("expected: '%s', actual: '%s'", test.want, got)
+			}
+		})
+	}
+
+	t.Run("Random Delimiters", func(t *testing.T) {
+		first, err := NewHighlighter()
+		if err != nil {
+			t.Fatalf("error in creating highlighter: %v", err)
+		}
+		second, _ := NewHighlighter()
+
+		if first.start == second.start || first.start == first.stop {
+			t.Errorf("delimiters should be unique: %+v, %+v", first, second)
+		}
+		if strings.ContainsAny(first.HeadlineOptions(), `"<>&`) {
+			t.Errorf("delimiters need no quoting or escaping: %s", first.HeadlineOptions())
+		}
+	})
+
+}
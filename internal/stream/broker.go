@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Event struct {
+	ID       uint64
+	Type     string
+	AuthorID uuid.UUID
+	Data     json.RawMessage
+}
+
+// Broker fans published events out to subscribers. It keeps a bounded history
+// so reconnecting clients can resume from the last event they saw, and never
+// blocks a publisher: a subscriber whose buffer is full is dropped instead.
+type Broker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	broker  *Broker
+	filter  func(Event) bool
+	events  chan Event
+	dropped chan struct{}
+}
+
+func NewBroker(historySize, bufferSize int) *Broker {
+	return &Broker{
+		// seeding IDs from the clock keeps them increasing across restarts, so
+		// a stale Last-Event-ID is seen as a gap rather than a future event
+		lastID:      uint64(time.Now().UnixMicro()),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+func (b *Broker) Publish(eventType string, authorID uuid.UUID, payload any) (Event, error) {
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("unable to encode event: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, AuthorID: authorID, Data: data}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+
+	return event, nil
+}
+
+// Subscribe registers for events matching filter (nil matches everything).
+// When lastEventID is non-zero the retained events after it are returned for
+// replay; complete is false if some of them have already been discarded.
+func (b *Broker) Subscribe(lastEventID uint64, filter func(Event) bool) (sub *Subscription, replay []Event, complete bool) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{
+		broker:  b,
+		filter:  filter,
+		events:  make(chan Event, b.bufferSize),
+		dropped: make(chan struct{}),
+	}
+	b.subscribers[sub] = struct{}{}
+
+	complete = true
+	if lastEventID == 0 {
+		return sub, nil, complete
+	}
+
+	if lastEventID < b.lastID && (len(b.history) == 0 || b.history[0].ID > lastEventID+1) {
+		complete = false
+	}
+
+	for _, event := range b.history {
+		if event.ID > lastEventID && (filter == nil || filter(event)) {
+			replay = append(replay, event)
+		}
+	}
+
+	return sub, replay, complete
+}
+
+// Events delivers live events in publish order
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped is closed when the subscriber fell too far behind and was removed
+func (s *Subscription) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+func (s *Subscription) Close() {
+
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	delete(s.broker.subscribers, s)
+}
+
+// drop must be called with b.mu held
+func (b *Broker) drop(sub *Subscription) {
+	delete(b.subscribers, sub)
+	close(sub.dropped)
+}
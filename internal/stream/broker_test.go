@@ -0,0 +1,146 @@
+package stream
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+
+	b := NewBroker(10, 10)
+	author1 := uuid.New()
+	author2 := uuid.New()
+
+	all, _, _ := b.Subscribe(0, nil)
+	defer all.Close()
+
+	filtered, _, _ := b.Subscribe(0, func(e Event) bool { return e.AuthorID == author1 })
+	defer filtered.Close()
+
+	first, _ := b.Publish("chirp.created", author1, map[string]string{"body": "one"})
+	second, _ := b.Publish("chirp.created", author2, map[string]string{"body": "two"})
+
+	if second.ID <= first.ID {
+		t.Errorf("event IDs should increase: %d then %d", first.ID, second.ID)
+	}
+
+	for _, want := range []Event{first, second} {
+		got := <-all.Events()
+		if got.ID != want.ID {
+			t.Errorf("expected event %d, actual event %d", want.ID, got.ID)
+		}
+	}
+
+	got := <-filtered.Events()
+	if got.ID != first.ID {
+		t.Errorf("expected event %d, actual event %d", first.ID, got.ID)
+	}
+
+	select {
+	case e := <-filtered.Events():
+		t.Errorf("filtered subscriber received event from another author: %+v", e)
+	default:
+	}
+
+	if string(first.Data) != `{"body":"one"}` {
+		t.Errorf("unexpected event data: %s", first.Data)
+	}
+
+}
+
+func TestResume(t *testing.T) {
+
+	b := NewBroker(3, 10)
+
+	var events []Event
+	for range 5 {
+		e, _ := b.Publish("chirp.created", uuid.New(), nil)
+		events = append(events, e)
+	}
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		replay   int
+		complete bool
+	}{
+		{name: "Fresh Subscription", lastID: 0, replay: 0, complete: true},
+		{name: "Up To Date", lastID: events[4].ID, replay: 0, complete: true},
+		{name: "Within History", lastID: events[2].ID, replay: 2, complete: true},
+		{name: "Edge Of History", lastID: events[1].ID, replay: 3, complete: true},
+		{name: "Beyond History", lastID: events[0].ID, replay: 3, complete: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sub, replay, complete := b.Subscribe(test.lastID, nil)
+			defer sub.Close()
+
+			if len(replay) != test.replay {
+				t.Errorf("expected %d replayed events, actual: %d", test.replay, len(replay))
+			}
+			if complete != test.complete {
+				t.Errorf("complete == %v, expected %v", complete, test.complete)
+			}
+			if len(replay) > 0 && replay[len(replay)-1].ID != events[4].ID {
+				t.Errorf("replay should end with the latest event")
+			}
+		})
+	}
+
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+
+	b := NewBroker(10, 2)
+
+	slow, _, _ := b.Subscribe(0, nil)
+	defer slow.Close()
+
+	fast, _, _ := b.Subscribe(0, nil)
+	defer fast.Close()
+
+	var wg sync.WaitGroup
+	received := 0
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range fast.Events() {
+			received++
+			if received == 5 {
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for range 5 {
+			b.Publish("chirp.created", uuid.New(), nil)
+			// give the fast reader a chance to keep up
+			time.Sleep(time.Millisecond)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publisher blocked on a slow subscriber")
+	}
+
+	select {
+	case <-slow.Dropped():
+	default:
+		t.Error("slow subscriber should have been dropped")
+	}
+
+	wg.Wait()
+	if received != 5 {
+		t.Errorf("fast subscriber expected 5 events, actual: %d", received)
+	}
+
+}
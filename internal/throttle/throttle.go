@@ -0,0 +1,123 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy describes how quickly repeated failures lock a key out
+type Policy struct {
+	// FreeAttempts failures are allowed before any lockout
+	FreeAttempts int
+	// BaseDelay is the first lockout, doubling with each further failure
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// ResetAfter is how long a key must go without failing before its count
+	// is forgotten
+	ResetAfter time.Duration
+}
+
+// Delay is the lockout that follows the given number of consecutive failures
+func (p Policy) Delay(failures int) time.Duration {
+
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < over && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay)
+}
+
+// Limiter counts failures per key, such as an account or client address, and
+// locks a key out with exponential backoff once it fails too often. State is
+// kept in memory, so lockouts don't survive a restart.
+type Limiter struct {
+	policy Policy
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastPrune time.Time
+}
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func New(policy Policy) *Limiter {
+	return &Limiter{policy: policy, entries: map[string]*entry{}}
+}
+
+// Locked reports whether key is locked out at now and for how much longer
+func (l *Limiter) Locked(key string, now time.Time) (time.Duration, bool) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok || !now.Before(e.lockedUntil) {
+		return 0, false
+	}
+
+	return e.lockedUntil.Sub(now), true
+}
+
+// Failure records a failed attempt for key, returning the lockout it now
+// has, if any
+func (l *Limiter) Failure(key string, now time.Time) time.Duration {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	e, ok := l.entries[key]
+	if !ok || l.stale(e, now) {
+		e = &entry{}
+		l.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	delay := l.policy.Delay(e.failures)
+	e.lockedUntil = now.Add(delay)
+
+	return delay
+}
+
+// Reset clears key's failures, after a successful attempt or when an admin
+// unlocks it
+func (l *Limiter) Reset(key string) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+func (l *Limiter) stale(e *entry, now time.Time) bool {
+	return now.Sub(e.lastFailure) >= l.policy.ResetAfter && !now.Before(e.lockedUntil)
+}
+
+// prune drops forgotten keys so failures from many addresses don't grow the
+// map forever; it runs at most once per ResetAfter
+func (l *Limiter) prune(now time.Time) {
+
+	if now.Sub(l.lastPrune) < l.policy.ResetAfter {
+		return
+	}
+	l.lastPrune = now
+
+	for key, e := range l.entries {
+		if l.stale(e, now) {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     10 * time.Second,
+	ResetAfter:   time.Hour,
+}
+
+func TestPolicyDelay(t *testing.T) {
+
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{failures: 0, delay: 0},
+		{failures: 3, delay: 0},
+		{failures: 4, delay: time.Second},
+		{failures: 5, delay: 2 * time.Second},
+		{failures: 7, delay: 8 * time.Second},
+		{failures: 8, delay: 10 * time.Second},
+		{failures: 100, delay: 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		if got := testPolicy.Delay(test.failures); got != test.delay {
+			t.Errorf("%d failures - expected: %v, actual: %v", test.failures, test.delay, got)
+		}
+	}
+
+}
+
+func TestLimiter(t *testing.T) {
+
+	l := New(testPolicy)
+	now := time.Unix(1700000000, 0)
+
+	for range testPolicy.FreeAttempts {
+		if delay := l.Failure("a", now); delay != 0 {
+			t.Fatalf("free attempt locked out for %v", delay)
+		}
+	}
+	if _, locked := l.Locked("a", now); locked {
+		t.Fatal("key should not be locked after free attempts")
+	}
+
+	if delay := l.Failure("a", now); delay != time.Second {
+		t.Errorf("expected lockout of 1s, actual: %v", delay)
+	}
+
+	remaining, locked := l.Locked("a", now.Add(500*time.Millisecond))
+	if !locked || remaining != 500*time.Millisecond {
+		t.Errorf("expected 500ms remaining, actual: %v (locked %v)", remaining, locked)
+	}
+	if _, locked := l.Locked("a", now.Add(time.Second)); locked {
+		t.Error("lockout should end after its delay")
+	}
+	if _, locked := l.Locked("b", now); locked {
+		t.Error("other keys should not be locked")
+	}
+
+	t.Run("Backoff Continues", func(t *testing.T) {
+		if delay := l.Failure("a", now.Add(time.Second)); delay != 2*time.Second {
+			t.Errorf("expected lockout of 2s, actual: %v", delay)
+		}
+	})
+
+	t.Run("Forgotten After Reset Period", func(t *testing.T) {
+		if delay := l.Failure("a", now.Add(2*time.Hour)); delay != 0 {
+			t.Errorf("failures should be forgotten, locked out for %v", delay)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		for range 10 {
+			l.Failure("c", now)
+		}
+		l.Reset("c")
+		if _, locked := l.Locked("c", now); locked {
+			t.Error("key should be unlocked after reset")
+		}
+	})
+
+}
@@ -0,0 +1,160 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/adamsma/webserver/internal/auth"
+	"github.com/google/uuid"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserCreated  = "user.created"
+	EventUserUpgraded = "user.upgraded"
+)
+
+// EventTypes are the events an endpoint can subscribe to
+var EventTypes = []string{EventChirpCreated, EventChirpDeleted, EventUserCreated, EventUserUpgraded}
+
+func ValidEventType(eventType string) bool {
+	return slices.Contains(EventTypes, eventType)
+}
+
+// headers sent with every delivery. Receivers verify the signature with
+// auth.WebhookVerifier, passing the timestamp and signature headers.
+const (
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+	TimestampHeader = "Chirpy-Timestamp"
+	SignatureHeader = "Chirpy-Signature"
+)
+
+// Envelope is the body of every delivery. ID identifies the event, so a
+// receiver subscribed through more than one endpoint can spot duplicates.
+type Envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func NewEnvelope(eventType string, data any, now time.Time) ([]byte, error) {
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode %s event: %w", eventType, err)
+	}
+
+	return json.Marshal(Envelope{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: now.UTC(),
+		Data:      encoded,
+	})
+}
+
+// RetryPolicy spaces out attempts at a failing endpoint, doubling the wait
+// each time, and gives up after MaxAttempts
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy keeps trying for about a day
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 10, BaseDelay: 30 * time.Second, MaxDelay: 6 * time.Hour}
+
+// NextAttempt is how long to wait after the given number of failed
+// attempts, or false when the delivery should be dead-lettered instead
+func (p RetryPolicy) NextAttempt(attempts int) (time.Duration, bool) {
+
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay), true
+}
+
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			// a redirect would send the signed body somewhere the endpoint's
+			// owner didn't register
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Result describes one delivery attempt. Err is nil only for a 2xx
+// response.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// most of an error response is noise; enough is kept to debug with
+const maxResponseExcerpt = 512
+
+// Send posts body to url, signed with secret
+func (s *Sender) Send(
+	ctx context.Context,
+	url string,
+	secret string,
+	deliveryID uuid.UUID,
+	eventType string,
+	body []byte,
+) Result {
+
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{Err: fmt.Errorf("invalid request: %w", err)}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(SignatureHeader, auth.SignWebhook(secret, start, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseExcerpt))
+	// drain the rest so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	result := Result{StatusCode: resp.StatusCode, Duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Err = fmt.Errorf("endpoint responded %d: %s", resp.StatusCode, bytes.TrimSpace(excerpt))
+	}
+
+	return result
+}
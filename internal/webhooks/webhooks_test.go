@@ -0,0 +1,149 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adamsma/webserver/internal/auth"
+	"github.com/google/uuid"
+)
+
+func TestSend(t *testing.T) {
+
+	secret := "whsec_test"
+	verifier, err := auth.NewWebhookVerifier(time.Minute, secret)
+	if err != nil {
+		t.Fatalf("error in creating verifier: %v", err)
+	}
+
+	var received *http.Request
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+
+		err := verifier.Verify(
+			r.Header.Get(TimestampHeader),
+			r.Header.Get(SignatureHeader),
+			receivedBody,
+			time.Now(),
+		)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	body, err := NewEnvelope(EventChirpCreated, map[string]string{"body": "hello"}, time.Now())
+	if err != nil {
+		t.Fatalf("error in creating envelope: %v", err)
+	}
+
+	deliveryID := uuid.New()
+	sender := NewSender(5 * time.Second)
+
+	result := sender.Send(context.Background(), receiver.URL, secret, deliveryID, EventChirpCreated, body)
+	if result.Err != nil {
+		t.Fatalf("delivery failed: %v", result.Err)
+	}
+	if result.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status %d, actual: %d", http.StatusNoContent, result.StatusCode)
+	}
+
+	if received.Header.Get(EventHeader) != EventChirpCreated {
+		t.Errorf("expected event header '%s', actual: '%s'", EventChirpCreated, received.Header.Get(EventHeader))
+	}
+	if received.Header.Get(DeliveryHeader) != deliveryID.String() {
+		t.Errorf("expected delivery header '%s', actual: '%s'", deliveryID, received.Header.Get(DeliveryHeader))
+	}
+
+	envelope := Envelope{}
+	if err := json.Unmarshal(receivedBody, &envelope); err != nil {
+		t.Fatalf("error in decoding delivery: %v", err)
+	}
+	if envelope.Type != EventChirpCreated || string(envelope.Data) != `{"body":"hello"}` {
+		t.Errorf("unexpected envelope: %+v", envelope)
+	}
+
+	t.Run("Wrong Secret Rejected", func(t *testing.T) {
+		result := sender.Send(context.Background(), receiver.URL, "other", deliveryID, EventChirpCreated, body)
+		if result.Err == nil || result.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected a 401 failure, actual: %+v", result)
+		}
+	})
+
+}
+
+func TestSendFailures(t *testing.T) {
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, failing.URL, http.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+	}{
+		{name: "Server Error", url: failing.URL, statusCode: http.StatusServiceUnavailable},
+		{name: "Redirect Not Followed", url: redirecting.URL, statusCode: http.StatusFound},
+		{name: "Unreachable", url: closed.URL, statusCode: 0},
+	}
+
+	sender := NewSender(5 * time.Second)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := sender.Send(context.Background(), test.url, "secret", uuid.New(), EventUserCreated, []byte("{}"))
+			if result.Err == nil {
+				t.Fatal("expected delivery to fail")
+			}
+			if result.StatusCode != test.statusCode {
+				t.Errorf("expected status %d, actual: %d", test.statusCode, result.StatusCode)
+			}
+		})
+	}
+
+}
+
+func TestRetryPolicy(t *testing.T) {
+
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+		retry    bool
+	}{
+		{attempts: 1, delay: time.Minute, retry: true},
+		{attempts: 2, delay: 2 * time.Minute, retry: true},
+		{attempts: 3, delay: 4 * time.Minute, retry: true},
+		{attempts: 4, delay: 5 * time.Minute, retry: true},
+		{attempts: 5, retry: false},
+	}
+
+	for _, test := range tests {
+		delay, retry := policy.NextAttempt(test.attempts)
+		if retry != test.retry || delay != test.delay {
+			t.Errorf("after %d attempts - expected: (%v, %v), actual: (%v, %v)",
+				test.attempts, test.delay, test.retry, delay, retry)
+		}
+	}
+
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handleJWKS publishes the public signing keys so other services can verify
+// access tokens without sharing a secret
+func (cfg *apiConfig) handleJWKS(resp http.ResponseWriter, req *http.Request) {
+
+	// short enough that a newly added key is picked up well before it
+	// becomes primary
+	resp.Header().Set("Cache-Control", "public, max-age=300")
+
+	respondWithJSON(resp, http.StatusOK, cfg.keys.JWKS())
+
+}
@@ -5,9 +5,18 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"sync/atomic"
+	"strings"
+	"time"
 
+	"github.com/adamsma/webserver/internal/auth"
 	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/email"
+	"github.com/adamsma/webserver/internal/entitlements"
+	"github.com/adamsma/webserver/internal/metrics"
+	"github.com/adamsma/webserver/internal/moderation"
+	"github.com/adamsma/webserver/internal/stream"
+	"github.com/adamsma/webserver/internal/throttle"
+	"github.com/adamsma/webserver/internal/webhooks"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -34,53 +43,227 @@ func main() {
 	}
 
 	jwtSignSecret := os.Getenv("JWT_SIGN_SECRET")
-	if jwtSignSecret == "" {
-		log.Fatal("JWT_SIGN_SECRET must be set")
+	signingKeyList := os.Getenv("JWT_SIGNING_KEYS")
+	if jwtSignSecret == "" && signingKeyList == "" {
+		log.Fatal("JWT_SIGN_SECRET or JWT_SIGNING_KEYS must be set")
 	}
 
+	var signingKeys []auth.SigningKey
+	if signingKeyList != "" {
+		signingKeys, err = auth.LoadSigningKeys(signingKeyList)
+		if err != nil {
+			log.Fatalf("error loading signing keys: %s", err)
+		}
+	}
+
+	// the shared secret signs tokens until a key list takes over, after which
+	// it only verifies tokens issued before the switch
+	if jwtSignSecret != "" {
+		status := auth.KeyPrimary
+		if signingKeyList != "" {
+			status = auth.KeyActive
+		}
+
+		legacyKey, err := auth.NewSigningKey(auth.LegacyKeyID, "HS256", status, []byte(jwtSignSecret))
+		if err != nil {
+			log.Fatalf("error loading JWT_SIGN_SECRET: %s", err)
+		}
+		signingKeys = append(signingKeys, legacyKey)
+	}
+
+	keys, err := auth.NewKeyRing(signingKeys...)
+	if err != nil {
+		log.Fatalf("error building signing key ring: %s", err)
+	}
+
+	lifetimes := tokenLifetimes{
+		access:  lifetimeFromEnv("ACCESS_TOKEN_TTL", time.Hour),
+		refresh: lifetimeFromEnv("REFRESH_TOKEN_TTL", 60*24*time.Hour),
+
+		passwordReset:     lifetimeFromEnv("PASSWORD_RESET_TTL", time.Hour),
+		emailVerification: lifetimeFromEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+	}
+	lifetimes.maxAccess = lifetimeFromEnv("ACCESS_TOKEN_MAX_TTL", lifetimes.access)
+	if lifetimes.maxAccess < lifetimes.access {
+		log.Fatal("ACCESS_TOKEN_MAX_TTL must be at least ACCESS_TOKEN_TTL")
+	}
+
+	// POLKA_WEBHOOK_SECRETS is a comma separated list so a new secret can be
+	// added before Polka switches to it. POLKA_KEY keeps the older API key
+	// scheme working and can be dropped once every webhook is signed.
 	polkaKey := os.Getenv("POLKA_KEY")
-	if jwtSignSecret == "" {
-		log.Fatal("POLKA_KEY must be set")
+	var polkaWebhooks *auth.WebhookVerifier
+	if secrets := os.Getenv("POLKA_WEBHOOK_SECRETS"); secrets != "" {
+		polkaWebhooks, err = auth.NewWebhookVerifier(
+			lifetimeFromEnv("POLKA_WEBHOOK_TOLERANCE", 5*time.Minute),
+			strings.Split(secrets, ",")...,
+		)
+		if err != nil {
+			log.Fatalf("error in POLKA_WEBHOOK_SECRETS: %s", err)
+		}
 	}
+	if polkaKey == "" && polkaWebhooks == nil {
+		log.Fatal("POLKA_WEBHOOK_SECRETS or POLKA_KEY must be set")
+	}
+
+	moderationRules := moderation.DefaultRules()
+	if wordList := os.Getenv("MODERATION_WORD_LIST"); wordList != "" {
+		moderationRules, err = moderation.LoadRules(wordList)
+		if err != nil {
+			log.Fatalf("error loading moderation word list: %s", err)
+		}
+	}
+
+	perkMatrix := entitlements.DefaultMatrix()
+	if perkFile := os.Getenv("ENTITLEMENTS_FILE"); perkFile != "" {
+		perkMatrix, err = entitlements.LoadMatrix(perkFile)
+		if err != nil {
+			log.Fatalf("error loading entitlements: %s", err)
+		}
+	}
+
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+
+	// without an SMTP server, mail is written to a spool directory
+	mailFrom := os.Getenv("EMAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "Chirpy <no-reply@chirpy.local>"
+	}
+	var mailer email.Sender
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		mailer = email.NewSMTPSender(smtpAddr, mailFrom)
+	} else {
+		spoolDir := os.Getenv("EMAIL_SPOOL_DIR")
+		if spoolDir == "" {
+			spoolDir = "mail"
+		}
+		mailer = email.NewSpoolSender(spoolDir, mailFrom)
+	}
+	mailQueue := email.NewQueue(
+		mailer,
+		emailWorkers,
+		emailBacklog,
+		emailSendTimeout,
+		func(msg email.Message, err error) {
+			log.Printf("unable to send email %q: %s", msg.Subject, err)
+		},
+	)
+
+	// admin endpoints other than metrics are disabled without a key
+	adminKey := os.Getenv("ADMIN_API_KEY")
+
+	chirpyMetrics := metrics.New()
 
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             database.New(dbConn),
-		env:            platform,
-		secret:         jwtSignSecret,
-		paymentKey:     polkaKey,
+		db:         database.New(chirpyMetrics.InstrumentDB(dbConn)),
+		conn:       dbConn,
+		env:        platform,
+		keys:       keys,
+		paymentKey: polkaKey,
+		moderator:  moderation.New(moderationRules),
+		stream:     stream.NewBroker(1000, 64),
+		metrics:    chirpyMetrics,
+		lifetimes:  lifetimes,
+		mailer:     mailQueue,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		adminKey:   adminKey,
+
+		entitlements: perkMatrix,
+
+		accountLockout: throttle.New(accountLockoutPolicy),
+		addrLockout:    throttle.New(addrLockoutPolicy),
+
+		paymentWebhooks: polkaWebhooks,
+
+		webhookSender: webhooks.NewSender(webhookSendTimeout),
 	}
 
 	sMux := http.NewServeMux()
 	sMux.Handle(
-		"/app/",
-		apiCfg.middlewareMetricsInc(
-			http.StripPrefix("/app", http.FileServer(http.Dir("."))),
-		),
+		fileserverRoute,
+		http.StripPrefix("/app", http.FileServer(http.Dir("."))),
 	)
 
 	sMux.HandleFunc("GET /api/healthz", handlerHealth)
 
-	sMux.HandleFunc("POST /api/chirps", apiCfg.handleNewChirp)
-	sMux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
-	sMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handleGetChirpByID)
-	sMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handleDeleteChirp)
+	sMux.HandleFunc("POST /api/chirps", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleNewChirp))
+	sMux.HandleFunc("GET /api/chirps", apiCfg.optionalAuth(apiCfg.handleGetChirps))
+	sMux.HandleFunc("GET /api/chirps/search", apiCfg.optionalAuth(apiCfg.handleSearchChirps))
+	sMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.optionalAuth(apiCfg.handleGetChirpByID))
+	sMux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleUpdateChirp))
+	sMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleDeleteChirp))
+	sMux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handleGetChirpRevisions)
+	sMux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.optionalAuth(apiCfg.handleGetChirpThread))
+	sMux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleLikeChirp))
+	sMux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleUnlikeChirp))
+
+	sMux.HandleFunc("GET /api/scheduled-chirps", apiCfg.requireAuth(auth.ScopeChirpsRead, apiCfg.handleGetScheduledChirps))
+	sMux.HandleFunc("DELETE /api/scheduled-chirps/{chirpID}", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handleDeleteScheduledChirp))
 
 	sMux.HandleFunc("POST /api/users", apiCfg.handleCreateUser)
-	sMux.HandleFunc("PUT /api/users", apiCfg.handleUpdateUser)
+	sMux.HandleFunc("PUT /api/users", apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleUpdateUser))
+	sMux.HandleFunc("POST /api/users/verify-email", apiCfg.handleVerifyEmail)
+	sMux.HandleFunc(
+		"POST /api/users/verify-email/resend",
+		apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleResendEmailVerification),
+	)
+	sMux.HandleFunc("GET /api/users/me/subscription", apiCfg.requireAuth(auth.ScopeUsersRead, apiCfg.handleGetSubscription))
+	sMux.HandleFunc("GET /api/users/me/entitlements", apiCfg.requireAuth(auth.ScopeUsersRead, apiCfg.handleGetEntitlements))
+
+	sMux.HandleFunc("POST /api/users/mfa", apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleStartMFAEnrollment))
+	sMux.HandleFunc("GET /api/users/mfa/qr.png", apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleGetMFAQRCode))
+	sMux.HandleFunc("POST /api/users/mfa/verify", apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleConfirmMFAEnrollment))
+	sMux.HandleFunc("DELETE /api/users/mfa", apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleDisableMFA))
+	sMux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleFollowUser))
+	sMux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.requireAuth(auth.ScopeUsersWrite, apiCfg.handleUnfollowUser))
+	sMux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handleGetFollowers)
+	sMux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handleGetFollowing)
+
+	sMux.HandleFunc("GET /api/timeline", apiCfg.requireAuth(auth.ScopeChirpsRead, apiCfg.handleGetTimeline))
+
+	sMux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.optionalAuth(apiCfg.handleGetHashtagChirps))
+	sMux.HandleFunc("GET /api/trending", apiCfg.handleGetTrending)
+
+	sMux.HandleFunc("GET /api/stream", apiCfg.handleStreamSSE)
+	sMux.HandleFunc("GET /api/stream/ws", apiCfg.handleStreamWebSocket)
 
 	sMux.HandleFunc("POST /api/login", apiCfg.handleLogin)
+	sMux.HandleFunc("POST /api/login/mfa", apiCfg.handleLoginMFA)
+
+	sMux.HandleFunc("POST /api/password-reset/request", apiCfg.handleRequestPasswordReset)
+	sMux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handleConfirmPasswordReset)
 
 	sMux.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshToken)
 	sMux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeRefresh)
 
 	sMux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlePolkaWebhook)
 
+	sMux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handleJWKS)
+
 	sMux.HandleFunc("GET /admin/metrics", apiCfg.handlerHits)
+	sMux.Handle("GET /admin/metrics/prometheus", apiCfg.metrics.Handler())
 	sMux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	sMux.HandleFunc("POST /admin/users/{userID}/unlock", apiCfg.requireAdmin(apiCfg.handleAdminUnlockUser))
+	sMux.HandleFunc("GET /admin/webhooks/events", apiCfg.requireAdmin(apiCfg.handleAdminListWebhookEvents))
+	sMux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", apiCfg.requireAdmin(apiCfg.handleAdminReplayWebhookEvent))
+	sMux.HandleFunc("POST /admin/webhooks/endpoints", apiCfg.requireAdmin(apiCfg.handleAdminCreateWebhookEndpoint))
+	sMux.HandleFunc("GET /admin/webhooks/endpoints", apiCfg.requireAdmin(apiCfg.handleAdminListWebhookEndpoints))
+	sMux.HandleFunc("DELETE /admin/webhooks/endpoints/{endpointID}", apiCfg.requireAdmin(apiCfg.handleAdminDeleteWebhookEndpoint))
+	sMux.HandleFunc("POST /admin/webhooks/endpoints/{endpointID}/deactivate", apiCfg.requireAdmin(apiCfg.handleAdminDeactivateWebhookEndpoint))
+	sMux.HandleFunc("POST /admin/webhooks/endpoints/{endpointID}/activate", apiCfg.requireAdmin(apiCfg.handleAdminActivateWebhookEndpoint))
+	sMux.HandleFunc("GET /admin/webhooks/deliveries", apiCfg.requireAdmin(apiCfg.handleAdminListWebhookDeliveries))
+	sMux.HandleFunc("GET /admin/webhooks/deliveries/{deliveryID}/attempts", apiCfg.requireAdmin(apiCfg.handleAdminGetWebhookDeliveryAttempts))
+	sMux.HandleFunc("POST /admin/webhooks/deliveries/{deliveryID}/retry", apiCfg.requireAdmin(apiCfg.handleAdminRetryWebhookDelivery))
+
+	go apiCfg.expireSubscriptions(lifetimeFromEnv("SUBSCRIPTION_EXPIRY_INTERVAL", 10*time.Minute))
+	go apiCfg.publishScheduledChirps(lifetimeFromEnv("SCHEDULED_CHIRP_INTERVAL", time.Minute))
+	go apiCfg.deliverWebhooks(lifetimeFromEnv("WEBHOOK_DELIVERY_INTERVAL", 5*time.Second))
 
 	server := &http.Server{
-		Handler: sMux,
+		Handler: apiCfg.metrics.Middleware(sMux),
 		Addr:    ":" + port,
 	}
 
@@ -88,3 +271,23 @@ func main() {
 	log.Fatal(server.ListenAndServe())
 
 }
+
+// lifetimeFromEnv reads a duration such as "15m" or "720h" from the
+// environment, falling back when unset
+func lifetimeFromEnv(name string, fallback time.Duration) time.Duration {
+
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+
+	lifetime, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("%s is not a valid duration: %s", name, err)
+	}
+	if lifetime <= 0 {
+		log.Fatalf("%s must be positive", name)
+	}
+
+	return lifetime
+}
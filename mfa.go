@@ -0,0 +1,550 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamsma/webserver/internal/auth"
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// time allowed between the password and second factor steps of a login
+	mfaChallengeLifetime = 5 * time.Minute
+	mfaIssuer            = "Chirpy"
+	mfaRecoveryCodeCount = 10
+	mfaQRCodeSize        = 256
+)
+
+// respondWithMFAChallenge answers a correct password for an account with
+// two-factor authentication. The challenge token is exchanged at
+// /api/login/mfa along with a code for the real tokens.
+func (cfg *apiConfig) respondWithMFAChallenge(resp http.ResponseWriter, userID uuid.UUID, scopes []auth.Scope) {
+
+	type response struct {
+		MFARequired bool      `json:"mfa_required"`
+		MFAToken    string    `json:"mfa_token"`
+		ExpiresAt   time.Time `json:"mfa_token_expires_at"`
+	}
+
+	expiresAt := time.Now().UTC().Add(mfaChallengeLifetime).Truncate(time.Second)
+	challenge, err := auth.MakeMFAChallenge(userID, cfg.keys, expiresAt, scopes)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to log in",
+			fmt.Errorf("error in making MFA challenge: %s", err),
+		)
+		return
+	}
+
+	respondWithJSON(
+		resp,
+		http.StatusOK,
+		response{MFARequired: true, MFAToken: challenge, ExpiresAt: expiresAt},
+	)
+
+}
+
+func (cfg *apiConfig) handleLoginMFA(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+		ExpiresIn    int    `json:"expires_in_seconds"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
+	if params.ExpiresIn < 0 {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"expires_in_seconds must be positive",
+			nil,
+		)
+		return
+	}
+
+	challenge, err := auth.ValidateMFAChallenge(params.MFAToken, cfg.keys)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
+			"Invalid or expired MFA token",
+			err,
+		)
+		return
+	}
+
+	lockoutKey := mfaLockoutKey(challenge.UserID)
+	if !cfg.checkLockout(resp, req, lockoutKey) {
+		return
+	}
+
+	mfa, err := cfg.db.GetUserMFA(req.Context(), challenge.UserID)
+	if err != nil || !mfa.EnabledAt.Valid {
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
+			"Invalid or expired MFA token",
+			fmt.Errorf("MFA login for %s without two-factor enabled: %v", challenge.UserID, err),
+		)
+		return
+	}
+
+	ok, err := cfg.checkSecondFactor(req.Context(), mfa, params.Code, params.RecoveryCode)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to log in",
+			err,
+		)
+		return
+	}
+	if !ok {
+		cfg.recordLoginFailure(req, lockoutKey)
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
+			"Invalid code",
+			fmt.Errorf("failed second factor for %s", challenge.UserID),
+		)
+		return
+	}
+
+	cfg.accountLockout.Reset(lockoutKey)
+
+	user, err := cfg.db.GetUserByID(req.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to log in",
+			fmt.Errorf("error in retrieving user %s: %s", challenge.UserID, err),
+		)
+		return
+	}
+
+	cfg.respondWithSession(resp, req, user, challenge.Scopes, params.ExpiresIn)
+
+}
+
+// checkSecondFactor accepts either a current TOTP code, which can't be
+// reused, or an unused recovery code, which is then spent
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, mfa database.UserMfa, code, recoveryCode string) (bool, error) {
+
+	if code != "" {
+		step, ok := auth.DefaultTOTP.Verify(mfa.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		recorded, err := cfg.db.RecordMFAStep(
+			ctx,
+			database.RecordMFAStepParams{UserID: mfa.UserID, LastUsedStep: step},
+		)
+		if err != nil {
+			return false, fmt.Errorf("error in recording MFA step: %w", err)
+		}
+
+		return recorded == 1, nil
+	}
+
+	if recoveryCode != "" {
+		used, err := cfg.db.UseMFARecoveryCode(
+			ctx,
+			database.UseMFARecoveryCodeParams{
+				UserID:   mfa.UserID,
+				CodeHash: auth.HashRecoveryCode(recoveryCode),
+			},
+		)
+		if err != nil {
+			return false, fmt.Errorf("error in using recovery code: %w", err)
+		}
+
+		return used == 1, nil
+	}
+
+	return false, nil
+}
+
+// handleStartMFAEnrollment generates a new secret for the user to add to an
+// authenticator app. Two-factor isn't enforced until a code from it has been
+// confirmed.
+func (cfg *apiConfig) handleStartMFAEnrollment(resp http.ResponseWriter, req *http.Request) {
+
+	type response struct {
+		Secret    string `json:"secret"`
+		URI       string `json:"otpauth_uri"`
+		QRCodeURL string `json:"qr_code_url"`
+	}
+
+	userID := requiredIdentity(req).UserID
+
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to start two-factor enrollment",
+			fmt.Errorf("error in retrieving user %s: %s", userID, err),
+		)
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to start two-factor enrollment",
+			err,
+		)
+		return
+	}
+
+	_, err = cfg.db.StartMFAEnrollment(
+		req.Context(),
+		database.StartMFAEnrollmentParams{UserID: userID, Secret: secret},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusConflict,
+			"Two-factor authentication is already enabled",
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to start two-factor enrollment",
+			fmt.Errorf("error in saving MFA secret: %s", err),
+		)
+		return
+	}
+
+	respondWithJSON(
+		resp,
+		http.StatusCreated,
+		response{
+			Secret:    secret,
+			URI:       auth.DefaultTOTP.URI(secret, mfaIssuer, user.Email),
+			QRCodeURL: "/api/users/mfa/qr.png",
+		},
+	)
+
+}
+
+func (cfg *apiConfig) handleGetMFAQRCode(resp http.ResponseWriter, req *http.Request) {
+
+	userID := requiredIdentity(req).UserID
+
+	mfa, err := cfg.db.GetUserMFA(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && mfa.EnabledAt.Valid) {
+		// the secret is only shown while enrolling
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"No two-factor enrollment in progress",
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to create QR code",
+			fmt.Errorf("error in retrieving MFA settings: %s", err),
+		)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to create QR code",
+			fmt.Errorf("error in retrieving user %s: %s", userID, err),
+		)
+		return
+	}
+
+	png, err := qrcode.Encode(
+		auth.DefaultTOTP.URI(mfa.Secret, mfaIssuer, user.Email),
+		qrcode.Medium,
+		mfaQRCodeSize,
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to create QR code",
+			err,
+		)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "image/png")
+	resp.Header().Set("Cache-Control", "no-store")
+	resp.WriteHeader(http.StatusOK)
+	resp.Write(png)
+
+}
+
+// handleConfirmMFAEnrollment turns on two-factor once the user shows a code
+// from their authenticator, returning recovery codes that are never shown
+// again
+func (cfg *apiConfig) handleConfirmMFAEnrollment(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	type response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID := requiredIdentity(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
+	mfa, err := cfg.db.GetUserMFA(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"No two-factor enrollment in progress",
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to enable two-factor authentication",
+			fmt.Errorf("error in retrieving MFA settings: %s", err),
+		)
+		return
+	}
+	if mfa.EnabledAt.Valid {
+		respondWithError(
+			resp,
+			http.StatusConflict,
+			"Two-factor authentication is already enabled",
+			nil,
+		)
+		return
+	}
+
+	step, ok := auth.DefaultTOTP.Verify(mfa.Secret, params.Code, time.Now())
+	if !ok {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid code",
+			nil,
+		)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(mfaRecoveryCodeCount)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to enable two-factor authentication",
+			err,
+		)
+		return
+	}
+
+	codeHashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		codeHashes = append(codeHashes, auth.HashRecoveryCode(code))
+	}
+
+	// the codes are only saved once this request has enabled MFA, so a
+	// concurrent confirmation can't replace the codes the other one returned
+	var enabled int64
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		var err error
+		enabled, err = q.EnableMFA(
+			req.Context(),
+			database.EnableMFAParams{UserID: userID, LastUsedStep: step},
+		)
+		if err != nil {
+			return fmt.Errorf("error in enabling MFA: %w", err)
+		}
+		if enabled == 0 {
+			return nil
+		}
+
+		err = q.ReplaceMFARecoveryCodes(
+			req.Context(),
+			database.ReplaceMFARecoveryCodesParams{UserID: userID, CodeHashes: codeHashes},
+		)
+		if err != nil {
+			return fmt.Errorf("error in saving recovery codes: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to enable two-factor authentication",
+			err,
+		)
+		return
+	}
+	if enabled == 0 {
+		// a concurrent request got there first
+		respondWithError(
+			resp,
+			http.StatusConflict,
+			"Two-factor authentication is already enabled",
+			nil,
+		)
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, response{RecoveryCodes: codes})
+
+}
+
+// handleDisableMFA needs a code or recovery code as well as the access token,
+// so a stolen session can't quietly switch two-factor off
+func (cfg *apiConfig) handleDisableMFA(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	userID := requiredIdentity(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
+	mfa, err := cfg.db.GetUserMFA(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"Two-factor authentication is not enabled",
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to disable two-factor authentication",
+			fmt.Errorf("error in retrieving MFA settings: %s", err),
+		)
+		return
+	}
+
+	// abandoning an unconfirmed enrollment needs no code
+	if mfa.EnabledAt.Valid {
+		lockoutKey := mfaLockoutKey(userID)
+		if !cfg.checkLockout(resp, req, lockoutKey) {
+			return
+		}
+
+		ok, err := cfg.checkSecondFactor(req.Context(), mfa, params.Code, params.RecoveryCode)
+		if err != nil {
+			respondWithError(
+				resp,
+				http.StatusInternalServerError,
+				"Unable to disable two-factor authentication",
+				err,
+			)
+			return
+		}
+		if !ok {
+			cfg.recordLoginFailure(req, lockoutKey)
+			respondWithError(
+				resp,
+				http.StatusForbidden,
+				"Invalid code",
+				nil,
+			)
+			return
+		}
+	}
+
+	if err := cfg.db.DisableMFA(req.Context(), userID); err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to disable two-factor authentication",
+			fmt.Errorf("error in disabling MFA: %s", err),
+		)
+		return
+	}
+
+	if err := cfg.db.DeleteMFARecoveryCodes(req.Context(), userID); err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to disable two-factor authentication",
+			fmt.Errorf("error in deleting recovery codes: %s", err),
+		)
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+
+}
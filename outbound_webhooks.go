@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/pagination"
+	"github.com/adamsma/webserver/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// states of a queued delivery
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryDead      = "dead"
+)
+
+const (
+	webhookBatchSize = 20
+	// long enough for a whole batch to time out one delivery at a time
+	webhookLease       = 5 * time.Minute
+	webhookSendTimeout = 10 * time.Second
+)
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	// only returned when the endpoint is created
+	Secret string `json:"secret,omitempty"`
+}
+
+func databaseWebhookEndpointToWebhookEndpoint(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:         endpoint.ID,
+		URL:        endpoint.Url,
+		EventTypes: endpoint.EventTypes,
+		Active:     endpoint.Active,
+		CreatedAt:  endpoint.CreatedAt,
+	}
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int32          `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func databaseWebhookDeliveryToWebhookDelivery(delivery database.WebhookDelivery) WebhookDelivery {
+
+	converted := WebhookDelivery{
+		ID:         delivery.ID,
+		EndpointID: delivery.EndpointID,
+		EventType:  delivery.EventType,
+		Payload:    delivery.Payload,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+		LastError:  delivery.LastError,
+		CreatedAt:  delivery.CreatedAt,
+	}
+	if delivery.Status == deliveryPending {
+		converted.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		converted.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		converted.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+
+	return converted
+}
+
+type WebhookDeliveryAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  *int32    `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+// emitWebhookEvent queues an event for every endpoint subscribed to it. A
+// failure here is logged rather than failing the request that caused it.
+func (cfg *apiConfig) emitWebhookEvent(ctx context.Context, eventType string, data any) {
+
+	payload, err := webhooks.NewEnvelope(eventType, data, time.Now())
+	if err != nil {
+		log.Printf("unable to queue %s webhooks: %s", eventType, err)
+		return
+	}
+
+	_, err = cfg.db.EnqueueWebhookDeliveries(
+		ctx,
+		database.EnqueueWebhookDeliveriesParams{EventType: eventType, Payload: payload},
+	)
+	if err != nil {
+		log.Printf("unable to queue %s webhooks: %s", eventType, err)
+	}
+}
+
+// deliverWebhooks sends queued deliveries every interval until the process
+// exits
+func (cfg *apiConfig) deliverWebhooks(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := cfg.deliverDueWebhooks(context.Background()); err != nil {
+			log.Printf("error in delivering webhooks: %s", err)
+		}
+
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) deliverDueWebhooks(ctx context.Context) error {
+
+	for {
+		claimed, err := cfg.db.ClaimWebhookDeliveries(
+			ctx,
+			database.ClaimWebhookDeliveriesParams{
+				LeaseSeconds: webhookLease.Seconds(),
+				BatchSize:    webhookBatchSize,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("unable to claim deliveries: %w", err)
+		}
+
+		for _, delivery := range claimed {
+			cfg.attemptWebhookDelivery(ctx, delivery)
+		}
+
+		if len(claimed) < webhookBatchSize {
+			return nil
+		}
+	}
+}
+
+// attemptWebhookDelivery sends one delivery and records the attempt,
+// scheduling a retry or dead-lettering it if the endpoint didn't accept it
+func (cfg *apiConfig) attemptWebhookDelivery(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) {
+
+	result := cfg.webhookSender.Send(
+		ctx,
+		delivery.Url,
+		delivery.Secret,
+		delivery.ID,
+		delivery.EventType,
+		delivery.Payload,
+	)
+
+	attempt := database.CompleteWebhookDeliveryAttemptParams{
+		ID: delivery.ID,
+		StatusCode: sql.NullInt32{
+			Int32: int32(result.StatusCode),
+			Valid: result.StatusCode != 0,
+		},
+		DurationMs: int32(result.Duration.Milliseconds()),
+		Status:     deliveryDelivered,
+	}
+
+	if result.Err != nil {
+		attempt.Error = result.Err.Error()
+
+		delay, retry := webhooks.DefaultRetryPolicy.NextAttempt(int(delivery.Attempts) + 1)
+		if retry {
+			attempt.Status = deliveryPending
+			attempt.RetryAfterSeconds = delay.Seconds()
+		} else {
+			attempt.Status = deliveryDead
+			log.Printf("webhook delivery %s dead-lettered after %d attempts: %s",
+				delivery.ID, delivery.Attempts+1, result.Err)
+		}
+	}
+
+	if err := cfg.db.CompleteWebhookDeliveryAttempt(ctx, attempt); err != nil {
+		// the lease runs out and the delivery is sent again, which receivers
+		// can detect with the delivery header
+		log.Printf("unable to record attempt for webhook delivery %s: %s", delivery.ID, err)
+	}
+}
+
+func (cfg *apiConfig) handleAdminCreateWebhookEndpoint(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
+	target, err := url.Parse(params.URL)
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"url must be an absolute http or https URL",
+			err,
+		)
+		return
+	}
+
+	if len(params.EventTypes) == 0 {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"At least one event type is required",
+			nil,
+		)
+		return
+	}
+	for _, eventType := range params.EventTypes {
+		if !webhooks.ValidEventType(eventType) {
+			respondWithError(
+				resp,
+				http.StatusBadRequest,
+				fmt.Sprintf("Unknown event type %q", eventType),
+				nil,
+			)
+			return
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to create webhook endpoint",
+			err,
+		)
+		return
+	}
+	secret := "whsec_" + hex.EncodeToString(raw)
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(
+		req.Context(),
+		database.CreateWebhookEndpointParams{
+			Url:        target.String(),
+			Secret:     secret,
+			EventTypes: params.EventTypes,
+		},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to create webhook endpoint",
+			fmt.Errorf("error in saving webhook endpoint: %s", err),
+		)
+		return
+	}
+
+	created := databaseWebhookEndpointToWebhookEndpoint(endpoint)
+	created.Secret = endpoint.Secret
+
+	respondWithJSON(resp, http.StatusCreated, created)
+
+}
+
+func (cfg *apiConfig) handleAdminListWebhookEndpoints(resp http.ResponseWriter, req *http.Request) {
+
+	endpoints, err := cfg.db.ListWebhookEndpoints(req.Context())
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve webhook endpoints",
+			fmt.Errorf("error in listing webhook endpoints: %s", err),
+		)
+		return
+	}
+
+	returnEndpoints := []WebhookEndpoint{}
+	for _, endpoint := range endpoints {
+		returnEndpoints = append(returnEndpoints, databaseWebhookEndpointToWebhookEndpoint(endpoint))
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnEndpoints)
+
+}
+
+// handleAdminDeleteWebhookEndpoint removes an endpoint along with its queued
+// deliveries and their logs
+func (cfg *apiConfig) handleAdminDeleteWebhookEndpoint(resp http.ResponseWriter, req *http.Request) {
+
+	endpointID, err := uuid.Parse(req.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid endpoint ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookEndpoint(req.Context(), endpointID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to delete webhook endpoint",
+			fmt.Errorf("error in deleting webhook endpoint %s: %s", endpointID, err),
+		)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(resp, http.StatusNotFound, "Webhook endpoint not found", nil)
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+
+}
+
+// handleAdminDeactivateWebhookEndpoint stops sending events to an endpoint.
+// It gets no new events while inactive, and deliveries already queued for it
+// are held until it is reactivated.
+func (cfg *apiConfig) handleAdminDeactivateWebhookEndpoint(resp http.ResponseWriter, req *http.Request) {
+	cfg.setWebhookEndpointActive(resp, req, false)
+}
+
+func (cfg *apiConfig) handleAdminActivateWebhookEndpoint(resp http.ResponseWriter, req *http.Request) {
+	cfg.setWebhookEndpointActive(resp, req, true)
+}
+
+func (cfg *apiConfig) setWebhookEndpointActive(resp http.ResponseWriter, req *http.Request, active bool) {
+
+	endpointID, err := uuid.Parse(req.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid endpoint ID", err)
+		return
+	}
+
+	endpoint, err := cfg.db.SetWebhookEndpointActive(
+		req.Context(),
+		database.SetWebhookEndpointActiveParams{ID: endpointID, Active: active},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(resp, http.StatusNotFound, "Webhook endpoint not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to update webhook endpoint",
+			fmt.Errorf("error in updating webhook endpoint %s: %s", endpointID, err),
+		)
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, databaseWebhookEndpointToWebhookEndpoint(endpoint))
+
+}
+
+// handleAdminListWebhookDeliveries lists deliveries newest first. Filter with
+// endpoint_id and status, and follow the next Link header for older
+// deliveries.
+func (cfg *apiConfig) handleAdminListWebhookDeliveries(resp http.ResponseWriter, req *http.Request) {
+
+	query := req.URL.Query()
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	cursor, err := adminListCursor(query.Get("cursor"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+
+	params := database.ListWebhookDeliveriesParams{
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageLimit:       int32(limit + 1),
+	}
+
+	if raw := query.Get("endpoint_id"); raw != "" {
+		endpointID, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(resp, http.StatusBadRequest, "Invalid endpoint ID", err)
+			return
+		}
+		params.EndpointID = uuid.NullUUID{UUID: endpointID, Valid: true}
+	}
+
+	switch raw := query.Get("status"); raw {
+	case "":
+	case deliveryPending, deliveryDelivered, deliveryDead:
+		params.Status = sql.NullString{String: raw, Valid: true}
+	default:
+		respondWithError(resp, http.StatusBadRequest, "Invalid status", nil)
+		return
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(req.Context(), params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve webhook deliveries",
+			fmt.Errorf("error in listing webhook deliveries: %s", err),
+		)
+		return
+	}
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		last := deliveries[len(deliveries)-1]
+		next := pagination.Cursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+			Direction: pagination.DirectionNext,
+		}
+		resp.Header().Set("Link", pageLink(req, next, "next"))
+	}
+
+	returnDeliveries := []WebhookDelivery{}
+	for _, delivery := range deliveries {
+		returnDeliveries = append(returnDeliveries, databaseWebhookDeliveryToWebhookDelivery(delivery))
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnDeliveries)
+
+}
+
+func (cfg *apiConfig) handleAdminGetWebhookDeliveryAttempts(resp http.ResponseWriter, req *http.Request) {
+
+	deliveryID, err := uuid.Parse(req.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	_, err = cfg.db.GetWebhookDelivery(req.Context(), deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(resp, http.StatusNotFound, "Webhook delivery not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve delivery attempts",
+			fmt.Errorf("error in retrieving webhook delivery %s: %s", deliveryID, err),
+		)
+		return
+	}
+
+	attempts, err := cfg.db.ListWebhookDeliveryAttempts(req.Context(), deliveryID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve delivery attempts",
+			fmt.Errorf("error in listing attempts for webhook delivery %s: %s", deliveryID, err),
+		)
+		return
+	}
+
+	returnAttempts := []WebhookDeliveryAttempt{}
+	for _, attempt := range attempts {
+		converted := WebhookDeliveryAttempt{
+			AttemptedAt: attempt.AttemptedAt,
+			Error:       attempt.Error,
+			DurationMs:  attempt.DurationMs,
+		}
+		if attempt.StatusCode.Valid {
+			converted.StatusCode = &attempt.StatusCode.Int32
+		}
+		returnAttempts = append(returnAttempts, converted)
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnAttempts)
+
+}
+
+// handleAdminRetryWebhookDelivery requeues a dead-lettered delivery, once
+// whatever broke the endpoint has been fixed
+func (cfg *apiConfig) handleAdminRetryWebhookDelivery(resp http.ResponseWriter, req *http.Request) {
+
+	deliveryID, err := uuid.Parse(req.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	requeued, err := cfg.db.RetryWebhookDelivery(req.Context(), deliveryID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retry webhook delivery",
+			fmt.Errorf("error in requeueing webhook delivery %s: %s", deliveryID, err),
+		)
+		return
+	}
+	if requeued == 0 {
+		respondWithError(
+			resp,
+			http.StatusConflict,
+			"Only dead-lettered deliveries can be retried",
+			nil,
+		)
+		return
+	}
+
+	resp.WriteHeader(http.StatusAccepted)
+
+}
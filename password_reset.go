@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/adamsma/webserver/internal/auth"
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/email"
+)
+
+const (
+	emailSendTimeout = 30 * time.Second
+	// sends in flight at once, and how many more can wait before new emails
+	// are dropped
+	emailWorkers = 4
+	emailBacklog = 256
+)
+
+// handleRequestPasswordReset emails a reset link if the address belongs to an
+// account. Both cases do the same work and give the same response so neither
+// can be used to find out who is registered.
+func (cfg *apiConfig) handleRequestPasswordReset(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to request password reset",
+			err,
+		)
+		return
+	}
+
+	created, err := cfg.db.CreatePasswordReset(
+		req.Context(),
+		database.CreatePasswordResetParams{
+			TokenHash:       auth.HashOneTimeToken(token),
+			LifetimeSeconds: cfg.lifetimes.passwordReset.Seconds(),
+			Email:           params.Email,
+		},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to request password reset",
+			fmt.Errorf("error in saving password reset: %s", err),
+		)
+		return
+	}
+
+	if created == 0 {
+		resp.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// queued rather than sent so response time doesn't reveal whether the
+	// account exists
+	msg := email.Message{
+		To:      params.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for your Chirpy account.\n\n"+
+				"Use this link within %s to choose a new one:\n\n%s\n\n"+
+				"If it wasn't you, you can ignore this email.\n",
+			cfg.lifetimes.passwordReset, cfg.appLink("/reset-password", token),
+		),
+	}
+	cfg.queueEmail(msg)
+
+	resp.WriteHeader(http.StatusAccepted)
+
+}
+
+func (cfg *apiConfig) handleConfirmPasswordReset(resp http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
+		)
+		return
+	}
+
+	if params.Password == "" {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Password is required",
+			nil,
+		)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Unable to use password",
+			fmt.Errorf("error hashing password: %s", err),
+		)
+		return
+	}
+
+	// the token is only spent if the new password lands and every session
+	// held by whoever knew the old one is revoked
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		userID, err := q.ConsumePasswordReset(req.Context(), auth.HashOneTimeToken(params.Token))
+		if err != nil {
+			return fmt.Errorf("error in consuming password reset: %w", err)
+		}
+
+		err = q.UpdateUserPassword(
+			req.Context(),
+			database.UpdateUserPasswordParams{ID: userID, HashedPassword: hashedPassword},
+		)
+		if err != nil {
+			return fmt.Errorf("error in updating password: %w", err)
+		}
+
+		if _, err := q.RevokeUserRefreshTokens(req.Context(), userID); err != nil {
+			return fmt.Errorf("error in revoking refresh tokens for %s: %w", userID, err)
+		}
+
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid or expired reset token",
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to reset password",
+			err,
+		)
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+
+}
+
+// queueEmail hands msg to the mail workers so the request doesn't wait on
+// the mail server
+func (cfg *apiConfig) queueEmail(msg email.Message) {
+	if err := cfg.mailer.Enqueue(msg); err != nil {
+		log.Printf("unable to queue email %q: %s", msg.Subject, err)
+	}
+}
+
+// appLink builds a link into the web app carrying a token for the page to
+// submit back to the API
+func (cfg *apiConfig) appLink(path, token string) string {
+	return cfg.baseURL + "/app" + path + "?" + url.Values{"token": {token}}.Encode()
+}
@@ -1,38 +1,112 @@
 package main
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/adamsma/webserver/internal/auth"
 	"github.com/adamsma/webserver/internal/database"
 	"github.com/google/uuid"
 )
 
-func (cfg *apiConfig) handlePolkaWebhook(resp http.ResponseWriter, req *http.Request) {
+const (
+	polkaTimestampHeader = "Polka-Timestamp"
+	polkaSignatureHeader = "Polka-Signature"
+	maxWebhookBodySize   = 1 << 20
+)
+
+// authenticatePolka accepts a signed webhook or, while it's still
+// configured, the older static API key. A request carrying a signature is
+// never allowed to fall back to the API key.
+func (cfg *apiConfig) authenticatePolka(headers http.Header, body []byte) error {
+
+	if signature := headers.Get(polkaSignatureHeader); signature != "" {
+		if cfg.paymentWebhooks == nil {
+			return errors.New("signed webhook received but no webhook secrets are configured")
+		}
+
+		return cfg.paymentWebhooks.Verify(
+			headers.Get(polkaTimestampHeader),
+			signature,
+			body,
+			time.Now(),
+		)
+	}
+
+	if cfg.paymentKey == "" {
+		return errors.New("webhook is not signed")
+	}
+
+	apiKey, err := auth.GetAPIKey(headers)
+	if err != nil {
+		return err
+	}
+	if !auth.ValidAPIKey(apiKey, cfg.paymentKey) {
+		return errors.New("invalid API key")
+	}
+
+	return nil
+}
 
-	type parameters struct {
-		Event string `json:"Event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
+// polkaEvent is the part of a Polka delivery the server acts on. Retries of
+// a delivery carry the same id, which is how they're recognised.
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           uuid.UUID  `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+		TrialEnd         *time.Time `json:"trial_end"`
+	} `json:"data"`
+}
+
+// subscriptionTerms is what an upgrade or renewal grants. Polka's older
+// events carry only the user, so they get the default plan with no end, as
+// Chirpy Red had before subscriptions.
+func (e polkaEvent) subscriptionTerms(now time.Time) database.UpsertSubscriptionParams {
+
+	terms := database.UpsertSubscriptionParams{
+		UserID:           e.Data.UserID,
+		Plan:             e.Data.Plan,
+		Status:           subscriptionActive,
+		CurrentPeriodEnd: endOfTime,
 	}
 
-	apiKey, err := auth.GetAPIKey(req.Header)
+	if terms.Plan == "" {
+		terms.Plan = defaultPlan
+	}
+
+	switch {
+	case e.Data.TrialEnd != nil && e.Data.TrialEnd.After(now):
+		terms.Status = subscriptionTrialing
+		terms.CurrentPeriodEnd = e.Data.TrialEnd.UTC()
+	case e.Data.CurrentPeriodEnd != nil:
+		terms.CurrentPeriodEnd = e.Data.CurrentPeriodEnd.UTC()
+	}
+
+	return terms
+}
+
+func (cfg *apiConfig) handlePolkaWebhook(resp http.ResponseWriter, req *http.Request) {
+
+	// the signature covers the exact bytes sent, so read them before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(resp, req.Body, maxWebhookBodySize))
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusUnauthorized,
-			"Invalid credentials",
+			http.StatusBadRequest,
+			"Couldn't read request body",
 			err,
 		)
 		return
 	}
 
-	if apiKey != cfg.paymentKey {
+	if err := cfg.authenticatePolka(req.Header, body); err != nil {
 		respondWithError(
 			resp,
 			http.StatusUnauthorized,
@@ -42,47 +116,91 @@ func (cfg *apiConfig) handlePolkaWebhook(resp http.ResponseWriter, req *http.Req
 		return
 	}
 
-	decoder := json.NewDecoder(req.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	event := polkaEvent{}
+	err = json.Unmarshal(body, &event)
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusInternalServerError,
+			http.StatusBadRequest,
 			"Couldn't decode parameters",
 			fmt.Errorf("error decoding parameters: %s", err),
 		)
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		resp.WriteHeader(http.StatusNoContent)
-		return
+	if event.ID == "" {
+		// with no ID from Polka a retry can't be told from a new event, so
+		// every such delivery is applied and logged on its own
+		event.ID = "unkeyed:" + uuid.NewString()
 	}
 
-	_, err = cfg.db.UpdateChirpyRedStatus(
+	claimed, err := cfg.db.RecordWebhookEvent(
 		req.Context(),
-		database.UpdateChirpyRedStatusParams{
-			ID: params.Data.UserID, IsChirpyRed: true,
+		database.RecordWebhookEventParams{
+			ID:        event.ID,
+			EventType: event.Event,
+			Payload:   body,
 		},
 	)
+	if err == nil && claimed == 0 {
+		// a retry; only redo the work if the earlier attempt failed or was
+		// abandoned part way through
+		claimed, err = cfg.db.ReclaimWebhookEvent(
+			req.Context(),
+			database.ReclaimWebhookEventParams{
+				ID:           event.ID,
+				LeaseSeconds: webhookEventLease.Seconds(),
+			},
+		)
+	}
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Couldn't record event",
+			fmt.Errorf("error in recording webhook event %s: %s", event.ID, err),
+		)
+		return
+	}
 
+	if claimed == 0 {
+		previous, err := cfg.db.GetWebhookEvent(req.Context(), event.ID)
+		if err != nil {
 			respondWithError(
 				resp,
-				http.StatusNotFound,
-				"Unable to find user",
-				err,
+				http.StatusInternalServerError,
+				"Couldn't record event",
+				fmt.Errorf("error in retrieving webhook event %s: %s", event.ID, err),
 			)
+			return
+		}
 
+		if previous.Outcome == webhookPending {
+			// another delivery of the event is being handled; Polka retries
+			// later and finds out how it went
+			respondWithError(
+				resp,
+				http.StatusConflict,
+				"Event is already being processed",
+				nil,
+			)
 			return
 		}
 
-		respondWithError(resp, http.StatusInternalServerError, "Couldn't update user", err)
+		resp.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	status, err := cfg.processPolkaEvent(req.Context(), event, false)
+	if status == http.StatusNotFound {
+		respondWithError(resp, status, "Unable to find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(resp, status, "Couldn't update user", err)
 		return
 	}
 
-	resp.WriteHeader(http.StatusNoContent)
+	resp.WriteHeader(status)
 
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/google/uuid"
+)
+
+type ScheduledChirp struct {
+	ID        uuid.UUID     `json:"id"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	PublishAt time.Time     `json:"publish_at"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func databaseScheduledChirpToScheduledChirp(chirp database.ScheduledChirp) ScheduledChirp {
+	return ScheduledChirp{
+		ID:        chirp.ID,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		InReplyTo: chirp.ParentID,
+		PublishAt: chirp.PublishAt,
+		CreatedAt: chirp.CreatedAt,
+	}
+}
+
+func (cfg *apiConfig) handleGetScheduledChirps(resp http.ResponseWriter, req *http.Request) {
+
+	userID := requiredIdentity(req).UserID
+
+	scheduled, err := cfg.db.ListScheduledChirps(req.Context(), userID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve scheduled chirps",
+			err,
+		)
+		return
+	}
+
+	returnChirps := []ScheduledChirp{}
+	for _, chirp := range scheduled {
+		returnChirps = append(returnChirps, databaseScheduledChirpToScheduledChirp(chirp))
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnChirps)
+
+}
+
+// handleDeleteScheduledChirp cancels a chirp that hasn't been published yet.
+// Cancelling is always allowed, even after losing the perk to schedule.
+func (cfg *apiConfig) handleDeleteScheduledChirp(resp http.ResponseWriter, req *http.Request) {
+
+	userID := requiredIdentity(req).UserID
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid chirp ID",
+			nil,
+		)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteScheduledChirp(
+		req.Context(),
+		database.DeleteScheduledChirpParams{ID: chirpID, UserID: userID},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to cancel scheduled chirp",
+			err,
+		)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"Scheduled chirp not found",
+			nil,
+		)
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+
+}
+
+// publishScheduledChirps posts scheduled chirps as they fall due, checking
+// every interval until the process exits. Perks were checked when each chirp
+// was scheduled, so they're published even if the author has since lost them.
+func (cfg *apiConfig) publishScheduledChirps(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := cfg.publishDueChirps(ctx); err != nil {
+			log.Printf("error in publishing scheduled chirps: %s", err)
+		}
+		cancel()
+
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) error {
+
+	var chirps []Chirp
+	err := cfg.inTx(ctx, func(q *database.Queries) error {
+		var err error
+		chirps, err = databaseChirpsToChirps(q.PublishDueScheduledChirps(ctx))
+		if err != nil {
+			return err
+		}
+
+		for _, chirp := range chirps {
+			if err := saveChirpEntities(ctx, q, chirp, false); err != nil {
+				return fmt.Errorf("chirp %s: %w", chirp.ID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, chirp := range chirps {
+		// the body was already masked when it was scheduled; moderating it
+		// again picks up the terms that only flag
+		cfg.recordModerationFlags(ctx, chirp.ID, cfg.moderator.Moderate(chirp.Body))
+
+		if err := cfg.attachMentions(ctx, []*Chirp{&chirp}); err != nil {
+			errs = append(errs, fmt.Errorf("chirp %s: %w", chirp.ID, err))
+		}
+
+		cfg.publishChirpEvent(streamEventChirpCreated, chirp.UserID, chirp)
+	}
+
+	return errors.Join(errs...)
+}
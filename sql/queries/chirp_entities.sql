@@ -0,0 +1,80 @@
+-- name: AddChirpHashtags :exec
+WITH tags AS (
+  INSERT INTO hashtags (id, tag, created_at)
+  SELECT gen_random_uuid(), tag, NOW()
+  FROM unnest(sqlc.arg(tags)::text[]) AS tag
+  ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+  RETURNING id
+)
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+SELECT sqlc.arg(chirp_id)::uuid, tags.id, sqlc.arg(created_at)::timestamp FROM tags
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags WHERE chirp_id = $1;
+
+-- name: AddChirpMentions :exec
+-- Any registered address can be mentioned. Responses only carry the user id,
+-- never the address, so they can't be used to probe for accounts.
+INSERT INTO chirp_mentions (chirp_id, user_id, mention)
+SELECT sqlc.arg(chirp_id)::uuid, users.id, lower(users.email)
+FROM users
+WHERE lower(users.email) = ANY(sqlc.arg(emails)::text[])
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions WHERE chirp_id = $1;
+
+-- name: GetChirpMentions :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetHashtagChirpsAfter :many
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id,
+  chirps.edited_at, chirps.parent_id, chirps.deleted_at, chirps.like_count
+FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = sqlc.arg(tag)
+  AND chirps.deleted_at IS NULL
+  AND (chirps.created_at, chirps.id) > (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetHashtagChirpsBefore :many
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id,
+  chirps.edited_at, chirps.parent_id, chirps.deleted_at, chirps.like_count
+FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = sqlc.arg(tag)
+  AND chirps.deleted_at IS NULL
+  AND (chirps.created_at, chirps.id) < (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetTrendingHashtags :many
+SELECT
+  hashtags.tag,
+  COUNT(*) AS uses,
+  SUM(
+    POWER(
+      0.5,
+      EXTRACT(EPOCH FROM NOW() - chirp_hashtags.created_at)
+        / sqlc.arg(half_life_seconds)::float8
+    )
+  )::float8 AS score
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE chirp_hashtags.created_at > NOW() - make_interval(
+  secs => sqlc.arg(window_seconds)::float8
+)
+GROUP BY hashtags.tag
+ORDER BY score DESC, hashtags.tag
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,3 @@
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags (id, chirp_id, terms, created_at)
+VALUES (gen_random_uuid(), $1, $2, NOW());
@@ -0,0 +1,27 @@
+-- name: LikeChirp :one
+WITH liked AS (
+  INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+  VALUES ($1, $2, NOW())
+  ON CONFLICT DO NOTHING
+  RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count + (SELECT COUNT(*) FROM liked)
+WHERE id = $2
+RETURNING like_count;
+
+-- name: UnlikeChirp :one
+WITH unliked AS (
+  DELETE FROM chirp_likes
+  WHERE user_id = $1 AND chirp_id = $2
+  RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count - (SELECT COUNT(*) FROM unliked)
+WHERE id = $2
+RETURNING like_count;
+
+-- name: GetLikedChirpIDs :many
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = sqlc.arg(user_id)
+  AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
@@ -1,19 +1,185 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING *;
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3)
+RETURNING
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count;
 
 -- name: GetChirps :many
-SELECT * FROM chirps 
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetChirpsByAuthor :many
-SELECT * FROM chirps
-WHERE user_id = $1
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
 ORDER BY created_at;
 
 -- name: GetChirpByID :one
-SELECT * FROM chirps WHERE id = $1;
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps WHERE id = $1;
+
+-- name: GetChirpsAfter :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE deleted_at IS NULL
+  AND (created_at, id) > (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetChirpsBefore :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE deleted_at IS NULL
+  AND (created_at, id) < (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetChirpsByAuthorAfter :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND (created_at, id) > (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetChirpsByAuthorBefore :many
+SELECT
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND (created_at, id) < (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: SearchChirps :many
+SELECT
+  chirps.id,
+  chirps.created_at,
+  chirps.updated_at,
+  chirps.body,
+  chirps.user_id,
+  chirps.edited_at,
+  chirps.parent_id,
+  chirps.like_count,
+  ts_rank_cd(chirps.search_vector, tsq)::real AS rank,
+  ts_headline(
+    'english', chirps.body, tsq, sqlc.arg(headline_options)::text
+  )::text AS snippet
+FROM chirps, to_tsquery('english', sqlc.arg(query)) tsq
+WHERE chirps.search_vector @@ tsq
+  AND chirps.deleted_at IS NULL
+  AND (
+    sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id)
+  )
+ORDER BY
+  CASE WHEN sqlc.arg(sort)::text = 'asc' THEN chirps.created_at END ASC,
+  CASE WHEN sqlc.arg(sort)::text = 'desc' THEN chirps.created_at END DESC,
+  rank DESC,
+  chirps.id
+LIMIT sqlc.arg(page_limit);
+
+-- name: UpdateChirpBody :one
+WITH revision AS (
+  INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+  SELECT gen_random_uuid(), id, body, COALESCE(edited_at, created_at), NOW()
+  FROM chirps
+  WHERE id = $1
+  FOR UPDATE
+)
+UPDATE chirps
+SET
+  body = $2,
+  edited_at = NOW(),
+  updated_at = NOW()
+WHERE id = $1
+RETURNING
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count;
+
+-- name: TombstoneChirp :one
+WITH scrubbed_revisions AS (
+  DELETE FROM chirp_revisions WHERE chirp_id = $1
+), scrubbed_hashtags AS (
+  DELETE FROM chirp_hashtags WHERE chirp_id = $1
+), scrubbed_mentions AS (
+  DELETE FROM chirp_mentions WHERE chirp_id = $1
+)
+UPDATE chirps
+SET
+  body = '',
+  deleted_at = NOW(),
+  updated_at = NOW()
+WHERE id = $1
+RETURNING
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count;
+
+-- name: ChirpHasReplies :one
+SELECT EXISTS (SELECT 1 FROM chirps WHERE parent_id = $1);
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    1 AS depth
+  FROM chirps c
+  WHERE c.id = (SELECT p.parent_id FROM chirps p WHERE p.id = $1)
+  UNION ALL
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    a.depth + 1
+  FROM chirps c
+  JOIN ancestors a ON c.id = a.parent_id
+)
+SELECT * FROM ancestors
+ORDER BY depth DESC;
+
+-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    1 AS depth
+  FROM chirps c
+  WHERE c.parent_id = $1
+  UNION ALL
+  SELECT
+    c.id, c.created_at, c.updated_at, c.body, c.user_id,
+    c.edited_at, c.parent_id, c.deleted_at, c.like_count,
+    d.depth + 1
+  FROM chirps c
+  JOIN descendants d ON c.parent_id = d.id
+)
+SELECT * FROM descendants
+ORDER BY depth, created_at, id;
@@ -0,0 +1,97 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowersBefore :many
+SELECT follower_id AS user_id, created_at FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+  AND (created_at, follower_id) < (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetFollowersAfter :many
+SELECT follower_id AS user_id, created_at FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+  AND (created_at, follower_id) > (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at ASC, follower_id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetFollowingBefore :many
+SELECT followee_id AS user_id, created_at FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+  AND (created_at, followee_id) < (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetFollowingAfter :many
+SELECT followee_id AS user_id, created_at FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+  AND (created_at, followee_id) > (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+ORDER BY created_at ASC, followee_id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetTimelineAfter :many
+-- reads at most a page from each followee's (user_id, created_at, id) index
+-- range and merges them, so the cost depends on how many users are followed
+-- rather than how many chirps exist overall
+WITH followees AS (
+  SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id)
+), page AS (
+  SELECT recent.id, recent.created_at FROM followees
+  CROSS JOIN LATERAL (
+    SELECT chirps.id, chirps.created_at FROM chirps
+    WHERE chirps.user_id = followees.followee_id
+      AND chirps.deleted_at IS NULL
+      AND (chirps.created_at, chirps.id) > (
+        sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+      )
+    ORDER BY chirps.created_at ASC, chirps.id ASC
+    LIMIT sqlc.arg(page_limit)
+  ) recent
+  ORDER BY recent.created_at ASC, recent.id ASC
+  LIMIT sqlc.arg(page_limit)
+)
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id,
+  chirps.edited_at, chirps.parent_id, chirps.deleted_at, chirps.like_count
+FROM chirps
+JOIN page ON page.id = chirps.id
+ORDER BY chirps.created_at ASC, chirps.id ASC;
+
+-- name: GetTimelineBefore :many
+WITH followees AS (
+  SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id)
+), page AS (
+  SELECT recent.id, recent.created_at FROM followees
+  CROSS JOIN LATERAL (
+    SELECT chirps.id, chirps.created_at FROM chirps
+    WHERE chirps.user_id = followees.followee_id
+      AND chirps.deleted_at IS NULL
+      AND (chirps.created_at, chirps.id) < (
+        sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+      )
+    ORDER BY chirps.created_at DESC, chirps.id DESC
+    LIMIT sqlc.arg(page_limit)
+  ) recent
+  ORDER BY recent.created_at DESC, recent.id DESC
+  LIMIT sqlc.arg(page_limit)
+)
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id,
+  chirps.edited_at, chirps.parent_id, chirps.deleted_at, chirps.like_count
+FROM chirps
+JOIN page ON page.id = chirps.id
+ORDER BY chirps.created_at DESC, chirps.id DESC;
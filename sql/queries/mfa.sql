@@ -0,0 +1,40 @@
+-- name: StartMFAEnrollment :one
+-- replaces an unconfirmed enrollment but never an enabled one
+INSERT INTO user_mfa (user_id, secret, created_at, updated_at)
+VALUES ($1, $2, NOW(), NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = NOW()
+WHERE user_mfa.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetUserMFA :one
+SELECT * FROM user_mfa WHERE user_id = $1;
+
+-- name: EnableMFA :execrows
+UPDATE user_mfa
+SET enabled_at = NOW(), last_used_step = $2, updated_at = NOW()
+WHERE user_id = $1 AND enabled_at IS NULL;
+
+-- name: RecordMFAStep :execrows
+-- fails when the code's step has already been used
+UPDATE user_mfa
+SET last_used_step = $2, updated_at = NOW()
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DisableMFA :exec
+DELETE FROM user_mfa WHERE user_id = $1;
+
+-- name: ReplaceMFARecoveryCodes :exec
+WITH cleared AS (
+  DELETE FROM mfa_recovery_codes WHERE user_id = sqlc.arg(user_id)
+)
+INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at)
+SELECT sqlc.arg(user_id)::uuid, unnest(sqlc.arg(code_hashes)::text[]), NOW();
+
+-- name: UseMFARecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteMFARecoveryCodes :exec
+DELETE FROM mfa_recovery_codes WHERE user_id = $1;
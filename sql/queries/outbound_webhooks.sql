@@ -0,0 +1,112 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, url, secret, event_types, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW())
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+ORDER BY created_at ASC, id ASC;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1;
+
+-- name: SetWebhookEndpointActive :one
+UPDATE webhook_endpoints
+SET active = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- queues the event for every active endpoint subscribed to it
+INSERT INTO webhook_deliveries (
+  id, endpoint_id, event_type, payload, next_attempt_at, created_at
+)
+SELECT
+  gen_random_uuid(),
+  webhook_endpoints.id,
+  sqlc.arg(event_type)::text,
+  sqlc.arg(payload)::jsonb,
+  NOW(),
+  NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.active
+  AND sqlc.arg(event_type)::text = ANY(webhook_endpoints.event_types);
+
+-- name: ClaimWebhookDeliveries :many
+-- leases a batch of due deliveries for lease_seconds. A worker that dies
+-- mid-batch leaves them to be picked up again once the lease runs out.
+-- Deliveries to inactive endpoints wait until the endpoint is reactivated.
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+FROM webhook_endpoints
+WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id
+  AND webhook_deliveries.id IN (
+    SELECT due.id FROM webhook_deliveries due
+    JOIN webhook_endpoints endpoint ON endpoint.id = due.endpoint_id
+    WHERE due.status = 'pending' AND due.next_attempt_at <= NOW()
+      AND endpoint.active
+    ORDER BY due.next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE OF due SKIP LOCKED
+  )
+RETURNING
+  webhook_deliveries.id,
+  webhook_deliveries.event_type,
+  webhook_deliveries.payload,
+  webhook_deliveries.attempts,
+  webhook_endpoints.url,
+  webhook_endpoints.secret;
+
+-- name: CompleteWebhookDeliveryAttempt :exec
+-- logs an attempt and moves the delivery to its next state
+WITH logged AS (
+  INSERT INTO webhook_delivery_attempts (
+    id, delivery_id, attempted_at, status_code, error, duration_ms
+  )
+  VALUES (
+    gen_random_uuid(),
+    sqlc.arg(id)::uuid,
+    NOW(),
+    sqlc.narg(status_code)::int,
+    sqlc.arg(error)::text,
+    sqlc.arg(duration_ms)::int
+  )
+)
+UPDATE webhook_deliveries
+SET
+  status = sqlc.arg(status)::text,
+  attempts = attempts + 1,
+  next_attempt_at = NOW() + make_interval(secs => sqlc.arg(retry_after_seconds)::float8),
+  last_attempt_at = NOW(),
+  last_status_code = sqlc.narg(status_code)::int,
+  last_error = sqlc.arg(error)::text,
+  delivered_at = CASE WHEN sqlc.arg(status)::text = 'delivered' THEN NOW() END
+WHERE id = sqlc.arg(id)::uuid;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE (created_at, id) < (
+    sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid
+  )
+  AND (
+    sqlc.narg(endpoint_id)::uuid IS NULL OR endpoint_id = sqlc.narg(endpoint_id)
+  )
+  AND (
+    sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = $1;
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at ASC, id ASC;
+
+-- name: RetryWebhookDelivery :execrows
+-- puts a dead-lettered delivery back in the queue with a fresh set of retries
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND status = 'dead';
@@ -0,0 +1,29 @@
+-- name: CreatePasswordReset :execrows
+-- saves a reset for the account registered to email, if there is one. The
+-- statement is the same either way so its timing doesn't give the answer away.
+INSERT INTO password_resets (token_hash, user_id, created_at, expires_at)
+SELECT
+  sqlc.arg(token_hash)::text,
+  users.id,
+  NOW(),
+  NOW() + make_interval(secs => sqlc.arg(lifetime_seconds)::float8)
+FROM users
+WHERE users.email = sqlc.arg(email);
+
+-- name: ConsumePasswordReset :one
+-- a reset link works once, and using one cancels any others outstanding
+WITH consumed AS (
+  UPDATE password_resets
+  SET used_at = NOW()
+  WHERE token_hash = $1
+    AND used_at IS NULL
+    AND expires_at > NOW()
+  RETURNING user_id
+), cancelled AS (
+  UPDATE password_resets
+  SET used_at = NOW()
+  WHERE user_id IN (SELECT user_id FROM consumed)
+    AND token_hash <> $1
+    AND used_at IS NULL
+)
+SELECT user_id FROM consumed;
@@ -1,12 +1,20 @@
 -- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (
-  token, 
+  token_hash, 
   created_at, 
   updated_at, 
   user_id, 
-  expires_at
+  expires_at,
+  scopes
+)
+VALUES (
+  sqlc.arg(token_hash),
+  NOW(),
+  NOW(),
+  sqlc.arg(user_id),
+  NOW() + make_interval(secs => sqlc.arg(lifetime_seconds)::float8),
+  sqlc.arg(scopes)
 )
-VALUES ($1, NOW(), NOW(), $2, NOW() + interval '60 days')
 RETURNING *;
 
 -- name: GetRefreshToken :one
@@ -14,9 +22,48 @@ SELECT
   *,
   expires_at < NOW() as is_expired
 FROM refresh_tokens 
-WHERE token = $1;
+WHERE token_hash = $1;
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
+WHERE token_hash = $1;
+
+-- name: RotateRefreshToken :one
+WITH rotated AS (
+  UPDATE refresh_tokens
+  SET revoked_at = NOW(), updated_at = NOW()
+  WHERE token_hash = sqlc.arg(old_token_hash)
+    AND revoked_at IS NULL
+    AND expires_at > NOW()
+  RETURNING user_id, family_id, scopes
+)
+INSERT INTO refresh_tokens (
+  token_hash,
+  created_at,
+  updated_at,
+  user_id,
+  expires_at,
+  family_id,
+  scopes
+)
+SELECT
+  sqlc.arg(new_token_hash)::text,
+  NOW(),
+  NOW(),
+  rotated.user_id,
+  NOW() + make_interval(secs => sqlc.arg(lifetime_seconds)::float8),
+  rotated.family_id,
+  rotated.scopes
+FROM rotated
+RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
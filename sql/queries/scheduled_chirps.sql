@@ -0,0 +1,41 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, user_id, body, parent_id, publish_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4::timestamptz, NOW())
+RETURNING *;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC, id ASC;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps WHERE id = $1 AND user_id = $2;
+
+-- name: PublishDueScheduledChirps :many
+-- moves every chirp whose time has come into chirps in one statement, so
+-- none can be published twice
+WITH due AS (
+  DELETE FROM scheduled_chirps
+  WHERE publish_at <= NOW()
+  RETURNING id, user_id, body, parent_id
+)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id)
+SELECT id, NOW(), NOW(), body, user_id, parent_id FROM due
+RETURNING
+  id, created_at, updated_at, body, user_id,
+  edited_at, parent_id, deleted_at, like_count;
+
+-- name: CountRecentChirps :one
+-- chirps the user has posted, or scheduled and not yet published, within the
+-- last window_seconds
+SELECT (
+  (
+    SELECT COUNT(*) FROM chirps
+    WHERE chirps.user_id = sqlc.arg(user_id)
+      AND chirps.created_at > NOW() - make_interval(secs => sqlc.arg(window_seconds)::float8)
+  ) + (
+    SELECT COUNT(*) FROM scheduled_chirps
+    WHERE scheduled_chirps.user_id = sqlc.arg(user_id)
+      AND scheduled_chirps.created_at > NOW() - make_interval(secs => sqlc.arg(window_seconds)::float8)
+  )
+)::bigint AS chirp_count;
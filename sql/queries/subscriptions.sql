@@ -0,0 +1,79 @@
+-- name: UpsertSubscription :one
+-- starts or renews a subscription, granting Chirpy Red. Returns no rows when
+-- the user doesn't exist.
+WITH upgraded AS (
+  UPDATE users
+  SET is_chirpy_red = true, updated_at = NOW()
+  WHERE id = sqlc.arg(user_id)
+  RETURNING id
+)
+INSERT INTO subscriptions (
+  user_id,
+  plan,
+  status,
+  current_period_end,
+  created_at,
+  updated_at
+)
+SELECT
+  upgraded.id,
+  sqlc.arg(plan)::text,
+  sqlc.arg(status)::text,
+  sqlc.arg(current_period_end)::timestamptz,
+  NOW(),
+  NOW()
+FROM upgraded
+ON CONFLICT (user_id) DO UPDATE
+SET
+  plan = EXCLUDED.plan,
+  status = EXCLUDED.status,
+  current_period_end = EXCLUDED.current_period_end,
+  canceled_at = NULL,
+  updated_at = NOW()
+RETURNING *, current_period_end > NOW() AS active;
+
+-- name: GetSubscription :one
+SELECT *, current_period_end > NOW() AS active
+FROM subscriptions
+WHERE user_id = $1;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', updated_at = NOW()
+WHERE user_id = $1 AND status <> 'canceled'
+RETURNING *;
+
+-- name: CancelSubscription :one
+-- access continues until the end of the paid period, or ends now when
+-- immediately is set
+WITH downgraded AS (
+  UPDATE users
+  SET is_chirpy_red = false, updated_at = NOW()
+  WHERE id = sqlc.arg(user_id) AND sqlc.arg(immediately)::bool
+)
+UPDATE subscriptions
+SET
+  status = 'canceled',
+  canceled_at = COALESCE(canceled_at, NOW()),
+  current_period_end = CASE
+    WHEN sqlc.arg(immediately)::bool THEN LEAST(current_period_end, NOW())
+    ELSE current_period_end
+  END,
+  updated_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :execrows
+-- ends Chirpy Red for every subscription past its period, returning how many
+-- users lost it
+WITH lapsed AS (
+  UPDATE subscriptions
+  SET status = 'canceled', canceled_at = NOW(), updated_at = NOW()
+  WHERE current_period_end <= NOW() AND status <> 'canceled'
+)
+UPDATE users
+SET is_chirpy_red = false, updated_at = NOW()
+WHERE is_chirpy_red
+  AND id IN (
+    SELECT user_id FROM subscriptions WHERE current_period_end <= NOW()
+  );
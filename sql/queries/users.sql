@@ -18,7 +18,7 @@ SELECT * FROM users WHERE email = $1;
 -- name: UpdateUserInfo :one
 UPDATE users
 SET 
-  email = $1,
+  pending_email = $1,
   hashed_password = $2,
   updated_at = NOW()
 WHERE id = $3
@@ -31,3 +31,29 @@ SET
   updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+  hashed_password = $2,
+  updated_at = NOW()
+WHERE id = $1;
+
+-- name: VerifyUserEmail :one
+-- only applies while the address is still the current or pending one, so a
+-- stale link can't undo a later change
+UPDATE users
+SET
+  email = sqlc.arg(email),
+  email_verified = true,
+  pending_email = CASE
+    WHEN pending_email = sqlc.arg(email) THEN NULL
+    ELSE pending_email
+  END,
+  updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND (email = sqlc.arg(email) OR pending_email = sqlc.arg(email))
+RETURNING *;
@@ -0,0 +1,56 @@
+-- name: RecordWebhookEvent :execrows
+-- claims a new event for processing. Affects no rows when the event has been
+-- seen before, so of several concurrent deliveries only one goes ahead.
+INSERT INTO webhook_events (id, event_type, payload, received_at, claimed_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ReclaimWebhookEvent :execrows
+-- claims an event for another try: one that failed, or one still pending
+-- whose claim is older than the lease, as its attempt must have died. Affects
+-- no rows otherwise, so only one retry can claim it.
+UPDATE webhook_events
+SET outcome = 'pending', claimed_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND (
+    outcome = 'failed'
+    OR (
+      outcome = 'pending'
+      AND claimed_at < NOW() - make_interval(secs => sqlc.arg(lease_seconds)::float8)
+    )
+  );
+
+-- name: ClaimWebhookEventReplay :execrows
+-- claims an event for an admin replay, whatever its earlier outcome. Affects
+-- no rows while another attempt holds an unexpired claim on it.
+UPDATE webhook_events
+SET outcome = 'pending', claimed_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND (
+    outcome <> 'pending'
+    OR claimed_at < NOW() - make_interval(secs => sqlc.arg(lease_seconds)::float8)
+  );
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: CompleteWebhookEvent :exec
+UPDATE webhook_events
+SET
+  outcome = $2,
+  detail = $3,
+  attempts = attempts + 1,
+  processed_at = NOW()
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (received_at, id) < (
+    sqlc.arg(cursor_received_at)::timestamp, sqlc.arg(cursor_id)::text
+  )
+  AND (
+    sqlc.narg(outcome)::text IS NULL OR outcome = sqlc.narg(outcome)
+  )
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN parent_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX chirps_parent_id_idx ON chirps (parent_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_parent_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at,
+DROP COLUMN parent_id;
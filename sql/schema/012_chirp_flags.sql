@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE chirp_flags (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  terms TEXT[] NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX chirp_flags_chirp_id_idx ON chirp_flags (chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_flags;
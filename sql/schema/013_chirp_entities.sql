@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE hashtags (
+  id UUID PRIMARY KEY,
+  tag TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE chirp_hashtags (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  hashtag_id UUID NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, hashtag_id)
+);
+
+CREATE INDEX chirp_hashtags_hashtag_id_idx ON chirp_hashtags (hashtag_id, created_at);
+CREATE INDEX chirp_hashtags_created_at_idx ON chirp_hashtags (created_at);
+
+CREATE TABLE chirp_mentions (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  mention TEXT NOT NULL,
+  PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id);
+CREATE INDEX users_lower_email_idx ON users (lower(email));
+
+-- +goose Down
+DROP INDEX IF EXISTS users_lower_email_idx;
+DROP TABLE chirp_mentions;
+DROP TABLE chirp_hashtags;
+DROP TABLE hashtags;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- every login starts a new family; rotated tokens inherit their parent's
+-- family so a replayed token can revoke all of its descendants
+ALTER TABLE refresh_tokens
+  ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
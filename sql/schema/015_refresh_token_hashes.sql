@@ -0,0 +1,12 @@
+-- +goose Up
+-- tokens are hashed in place so existing sessions keep working; clients still
+-- present the raw value, which is hashed with the same SHA-256 before lookup
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- +goose Down
+-- hashes can't be reversed, so rolling back logs everyone out
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
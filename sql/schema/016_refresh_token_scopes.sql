@@ -0,0 +1,11 @@
+-- +goose Up
+-- refresh tokens remember the scopes granted at login so rotation can't
+-- widen a limited token; existing sessions had full access
+ALTER TABLE refresh_tokens
+  ADD COLUMN scopes TEXT[] NOT NULL
+  DEFAULT ARRAY['chirps:read', 'chirps:write', 'users:write'];
+
+ALTER TABLE refresh_tokens ALTER COLUMN scopes DROP DEFAULT;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN scopes;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- a changed address waits in pending_email until the new owner confirms it
+ALTER TABLE users
+  ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN pending_email TEXT;
+
+CREATE TABLE email_verifications (
+  token_hash TEXT PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP
+);
+
+CREATE INDEX email_verifications_user_id_idx ON email_verifications (user_id);
+
+-- +goose Down
+DROP TABLE email_verifications;
+ALTER TABLE users DROP COLUMN pending_email, DROP COLUMN email_verified;
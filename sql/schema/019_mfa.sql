@@ -0,0 +1,24 @@
+-- +goose Up
+-- enabled_at stays NULL until the user proves their authenticator works.
+-- last_used_step is the TOTP counter of the last accepted code, so a code
+-- can't be replayed within its validity window.
+CREATE TABLE user_mfa (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret TEXT NOT NULL,
+  enabled_at TIMESTAMP,
+  last_used_step BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE mfa_recovery_codes (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  PRIMARY KEY (user_id, code_hash)
+);
+
+-- +goose Down
+DROP TABLE mfa_recovery_codes;
+DROP TABLE user_mfa;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- every Polka delivery, keyed by the event ID so retries are recognised.
+-- outcome is pending until the event has been handled, then processed,
+-- ignored or failed.
+CREATE TABLE webhook_events (
+  id TEXT PRIMARY KEY,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  received_at TIMESTAMP NOT NULL,
+  outcome TEXT NOT NULL DEFAULT 'pending',
+  detail TEXT NOT NULL DEFAULT '',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  processed_at TIMESTAMP
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
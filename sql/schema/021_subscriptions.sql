@@ -0,0 +1,27 @@
+-- +goose Up
+-- users.is_chirpy_red is kept as the answer to "does this user have Chirpy
+-- Red right now", which is true until current_period_end whatever the
+-- status. Cancelling stops renewal; access lapses at the end of the period.
+CREATE TABLE subscriptions (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  plan TEXT NOT NULL,
+  status TEXT NOT NULL,
+  current_period_end TIMESTAMP NOT NULL,
+  canceled_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_current_period_end_idx ON subscriptions (current_period_end);
+
+-- upgrades from before subscriptions were tracked had no end date, so they
+-- run until Polka sends a downgrade
+INSERT INTO subscriptions (
+  user_id, plan, status, current_period_end, created_at, updated_at
+)
+SELECT id, 'chirpy_red', 'active', '9999-12-31 23:59:59', NOW(), NOW()
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
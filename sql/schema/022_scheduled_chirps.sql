@@ -0,0 +1,18 @@
+-- +goose Up
+-- chirps waiting to be posted. When publish_at passes they're moved into
+-- chirps, keeping their id, so nothing that reads chirps has to know about
+-- scheduling.
+CREATE TABLE scheduled_chirps (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  body TEXT NOT NULL,
+  parent_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+  publish_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX scheduled_chirps_publish_at_idx ON scheduled_chirps (publish_at);
+CREATE INDEX scheduled_chirps_user_id_idx ON scheduled_chirps (user_id);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
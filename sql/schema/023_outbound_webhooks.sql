@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints (
+  id UUID PRIMARY KEY,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  event_types TEXT[] NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+-- the delivery queue. status is pending until the endpoint accepts the event
+-- (delivered) or retries run out (dead). While a worker is sending,
+-- next_attempt_at is pushed forward so no other worker picks it up.
+CREATE TABLE webhook_deliveries (
+  id UUID PRIMARY KEY,
+  endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_attempt_at TIMESTAMP,
+  last_status_code INTEGER,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  delivered_at TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id);
+
+CREATE TABLE webhook_delivery_attempts (
+  id UUID PRIMARY KEY,
+  delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+  attempted_at TIMESTAMP NOT NULL,
+  status_code INTEGER,
+  error TEXT NOT NULL DEFAULT '',
+  duration_ms INTEGER NOT NULL
+);
+
+CREATE INDEX webhook_delivery_attempts_delivery_id_idx ON webhook_delivery_attempts (delivery_id);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
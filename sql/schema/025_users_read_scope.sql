@@ -0,0 +1,10 @@
+-- +goose Up
+-- reading account details moved from chirps:read to its own scope; sessions
+-- that could already change the account keep being able to read it
+UPDATE refresh_tokens
+SET scopes = array_append(scopes, 'users:read')
+WHERE 'users:write' = ANY(scopes) AND NOT 'users:read' = ANY(scopes);
+
+-- +goose Down
+UPDATE refresh_tokens
+SET scopes = array_remove(scopes, 'users:read');
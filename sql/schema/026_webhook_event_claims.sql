@@ -0,0 +1,9 @@
+-- +goose Up
+-- when the current attempt at an event was claimed, so an attempt that died
+-- part way through can be taken over once its lease runs out
+ALTER TABLE webhook_events
+ADD COLUMN claimed_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE webhook_events
+DROP COLUMN claimed_at;
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adamsma/webserver/internal/stream"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamHeartbeat    = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+	// clients are told to wait this long before reconnecting
+	streamRetry = 3 * time.Second
+
+	streamEventChirpCreated = "chirp.created"
+	streamEventChirpDeleted = "chirp.deleted"
+	// sent when a resuming client missed events that are no longer retained
+	// and should refetch via the REST API
+	streamEventResync = "resync"
+)
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// publishChirpEvent notifies stream subscribers and queues webhooks for the
+// same event. Failures are logged rather than surfaced since the chirp itself
+// has already been saved.
+func (cfg *apiConfig) publishChirpEvent(eventType string, authorID uuid.UUID, payload any) {
+	if _, err := cfg.stream.Publish(eventType, authorID, payload); err != nil {
+		log.Printf("unable to publish %s event: %s", eventType, err)
+	}
+
+	// not tied to the request, which may already be finished for chirps
+	// published on a schedule
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cfg.emitWebhookEvent(ctx, eventType, payload)
+}
+
+// subscribeToStream parses the author_id filter and resume position shared by
+// the SSE and WebSocket endpoints
+func (cfg *apiConfig) subscribeToStream(
+	resp http.ResponseWriter,
+	req *http.Request,
+	lastEventID string,
+) (*stream.Subscription, []stream.Event, bool, error) {
+
+	var filter func(stream.Event) bool
+	if author := req.URL.Query().Get("author_id"); author != "" {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			respondWithError(resp, http.StatusBadRequest, "Invalid author ID", err)
+			return nil, nil, false, err
+		}
+
+		filter = func(e stream.Event) bool { return e.AuthorID == authorID }
+	}
+
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			respondWithError(resp, http.StatusBadRequest, "Invalid last event ID", err)
+			return nil, nil, false, err
+		}
+	}
+
+	sub, replay, complete := cfg.stream.Subscribe(lastID, filter)
+
+	return sub, replay, complete, nil
+}
+
+func (cfg *apiConfig) handleStreamSSE(resp http.ResponseWriter, req *http.Request) {
+
+	lastEventID := req.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = req.URL.Query().Get("last_event_id")
+	}
+
+	sub, replay, complete, err := cfg.subscribeToStream(resp, req, lastEventID)
+	if err != nil {
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(resp)
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("Connection", "keep-alive")
+	resp.Header().Set("X-Accel-Buffering", "no")
+	resp.WriteHeader(http.StatusOK)
+
+	// a client that stops reading fails the write instead of pinning this
+	// handler forever
+	write := func(format string, args ...any) error {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(resp, format, args...); err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	writeEvent := func(e stream.Event) error {
+		return write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	}
+
+	if err := write("retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	if !complete {
+		if err := write("event: %s\ndata: {}\n\n", streamEventResync); err != nil {
+			return
+		}
+	}
+
+	for _, e := range replay {
+		if err := writeEvent(e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-sub.Dropped():
+			// the client reconnects and resumes from its Last-Event-ID
+			return
+		case e := <-sub.Events():
+			if err := writeEvent(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+
+}
+
+func (cfg *apiConfig) handleStreamWebSocket(resp http.ResponseWriter, req *http.Request) {
+
+	type message struct {
+		ID    string `json:"id,omitempty"`
+		Event string `json:"event"`
+		Data  any    `json:"data"`
+	}
+
+	// browsers can't set headers on a WebSocket handshake
+	sub, replay, complete, err := cfg.subscribeToStream(resp, req, req.URL.Query().Get("last_event_id"))
+	if err != nil {
+		return
+	}
+	defer sub.Close()
+
+	conn, err := streamUpgrader.Upgrade(resp, req, nil)
+	if err != nil {
+		// the upgrader has already responded
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	// pongs extend the read deadline; anything else the client sends is
+	// ignored. A failed read means the connection is gone.
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	writeEvent := func(e stream.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return conn.WriteJSON(message{
+			ID:    strconv.FormatUint(e.ID, 10),
+			Event: e.Type,
+			Data:  e.Data,
+		})
+	}
+
+	if !complete {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		err := conn.WriteJSON(message{Event: streamEventResync, Data: struct{}{}})
+		if err != nil {
+			return
+		}
+	}
+
+	for _, e := range replay {
+		if err := writeEvent(e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Dropped():
+			conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+				time.Now().Add(streamWriteTimeout),
+			)
+			return
+		case e := <-sub.Events():
+			if err := writeEvent(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+			if err != nil {
+				return
+			}
+		}
+	}
+
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+)
+
+const (
+	subscriptionTrialing = "trialing"
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+)
+
+const defaultPlan = "chirpy_red"
+
+type Subscription struct {
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	Active           bool       `json:"active"`
+	CurrentPeriodEnd time.Time  `json:"current_period_end"`
+	CanceledAt       *time.Time `json:"canceled_at,omitempty"`
+}
+
+func databaseSubscriptionToSubscription(sub database.GetSubscriptionRow) Subscription {
+
+	converted := Subscription{
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		Active:           sub.Active,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+	}
+	if sub.CanceledAt.Valid {
+		converted.CanceledAt = &sub.CanceledAt.Time
+	}
+
+	return converted
+}
+
+func (cfg *apiConfig) handleGetSubscription(resp http.ResponseWriter, req *http.Request) {
+
+	userID := requiredIdentity(req).UserID
+
+	sub, err := cfg.db.GetSubscription(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusNotFound,
+			"No subscription found",
+			nil,
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve subscription",
+			fmt.Errorf("error in retrieving subscription for %s: %s", userID, err),
+		)
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, databaseSubscriptionToSubscription(sub))
+
+}
+
+// expireSubscriptions ends Chirpy Red for lapsed subscriptions every
+// interval, starting straight away to catch any that lapsed while the server
+// was down. It runs until the process exits.
+func (cfg *apiConfig) expireSubscriptions(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		expired, err := cfg.db.ExpireLapsedSubscriptions(ctx)
+		cancel()
+
+		if err != nil {
+			log.Printf("error in expiring subscriptions: %s", err)
+		} else if expired > 0 {
+			log.Printf("expired Chirpy Red for %d users", expired)
+		}
+
+		<-ticker.C
+	}
+}
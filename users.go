@@ -1,13 +1,18 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/adamsma/webserver/internal/auth"
 	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/email"
+	"github.com/adamsma/webserver/internal/webhooks"
 	"github.com/google/uuid"
 )
 
@@ -18,12 +23,29 @@ type User struct {
 	Email       string    `json:"email"`
 	Passord     string    `json:"-"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+
+	EmailVerified bool   `json:"email_verified"`
+	PendingEmail  string `json:"pending_email,omitempty"`
+}
+
+func databaseUserToUser(user database.User) User {
+	return User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerified,
+		PendingEmail:  user.PendingEmail.String,
+	}
 }
 
 type Credentials struct {
-	Email     string        `json:"email"`
-	Password  string        `json:"password"`
-	ExpiresIn time.Duration `json:"expires_in_seconds"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	ExpiresIn int    `json:"expires_in_seconds"`
+	// login only; defaults to full access
+	Scopes []string `json:"scopes"`
 }
 
 func (cfg *apiConfig) handleCreateUser(resp http.ResponseWriter, req *http.Request) {
@@ -46,6 +68,18 @@ func (cfg *apiConfig) handleCreateUser(resp http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	addr, err := email.ValidateAddress(params.Email)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"Invalid email address",
+			err,
+		)
+
+		return
+	}
+
 	hash, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(
@@ -60,7 +94,7 @@ func (cfg *apiConfig) handleCreateUser(resp http.ResponseWriter, req *http.Reque
 
 	user, err := cfg.db.CreateUser(
 		req.Context(),
-		database.CreateUserParams{Email: params.Email, HashedPassword: hash},
+		database.CreateUserParams{Email: addr, HashedPassword: hash},
 	)
 	if err != nil {
 		respondWithError(
@@ -73,25 +107,19 @@ func (cfg *apiConfig) handleCreateUser(resp http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	newUser := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+	// the account is usable straight away; the link only marks the address
+	// as verified and can be resent
+	if err := cfg.sendEmailVerification(req.Context(), user.ID, user.Email); err != nil {
+		log.Printf("unable to send verification email to new user %s: %s", user.ID, err)
 	}
 
-	respondWithJSON(resp, http.StatusCreated, response{User: newUser})
+	cfg.emitWebhookEvent(req.Context(), webhooks.EventUserCreated, databaseUserToUser(user))
+
+	respondWithJSON(resp, http.StatusCreated, response{User: databaseUserToUser(user)})
 }
 
 func (cfg *apiConfig) handleLogin(resp http.ResponseWriter, req *http.Request) {
 
-	type response struct {
-		AccessToken  string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-		User
-	}
-
 	decoder := json.NewDecoder(req.Body)
 	params := Credentials{}
 	err := decoder.Decode(&params)
@@ -106,12 +134,54 @@ func (cfg *apiConfig) handleLogin(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	tgtUser, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
+	if params.ExpiresIn < 0 {
+		respondWithError(
+			resp,
+			http.StatusBadRequest,
+			"expires_in_seconds must be positive",
+			nil,
+		)
+
+		return
+	}
+
+	scopes, err := auth.ParseScopes(params.Scopes)
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusNotFound,
+			http.StatusBadRequest,
+			"Invalid scopes requested",
+			err,
+		)
+
+		return
+	}
+
+	accountKey := accountLockoutKey(params.Email)
+	if !cfg.checkLockout(resp, req, accountKey) {
+		return
+	}
+
+	// unknown emails get the same response, after the same amount of work, as
+	// a wrong password
+	tgtUser, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		auth.CheckDummyPassword(params.Password)
+		cfg.recordLoginFailure(req, accountKey)
+		respondWithError(
+			resp,
+			http.StatusUnauthorized,
 			"Invalid email or password",
+			fmt.Errorf("failed login attempt for unknown user (%s)", params.Email),
+		)
+
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to log in",
 			fmt.Errorf(
 				"unable to retrieve user information (%s): %s", params.Email, err,
 			),
@@ -122,6 +192,7 @@ func (cfg *apiConfig) handleLogin(resp http.ResponseWriter, req *http.Request) {
 
 	err = auth.CheckPasswordHash(params.Password, tgtUser.HashedPassword)
 	if err != nil {
+		cfg.recordLoginFailure(req, accountKey)
 		respondWithError(
 			resp,
 			http.StatusUnauthorized,
@@ -134,15 +205,51 @@ func (cfg *apiConfig) handleLogin(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	activeUser := User{
-		ID:          tgtUser.ID,
-		CreatedAt:   tgtUser.CreatedAt,
-		UpdatedAt:   tgtUser.UpdatedAt,
-		Email:       tgtUser.Email,
-		IsChirpyRed: tgtUser.IsChirpyRed,
+	cfg.accountLockout.Reset(accountKey)
+
+	mfa, err := cfg.db.GetUserMFA(req.Context(), tgtUser.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to log in",
+			fmt.Errorf("error in retrieving two-factor settings: %s", err),
+		)
+
+		return
+	}
+	if err == nil && mfa.EnabledAt.Valid {
+		cfg.respondWithMFAChallenge(resp, tgtUser.ID, scopes)
+		return
+	}
+
+	cfg.respondWithSession(resp, req, tgtUser, scopes, params.ExpiresIn)
+
+}
+
+// respondWithSession completes a login, issuing an access token and a refresh
+// token starting a new family
+func (cfg *apiConfig) respondWithSession(
+	resp http.ResponseWriter,
+	req *http.Request,
+	user database.User,
+	scopes []auth.Scope,
+	expiresIn int,
+) {
+
+	type response struct {
+		AccessToken           string       `json:"token"`
+		ExpiresAt             time.Time    `json:"expires_at"`
+		RefreshToken          string       `json:"refresh_token"`
+		RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+		Scopes                []auth.Scope `json:"scopes"`
+		User
 	}
 
-	accessToken, err := auth.MakeJWT(activeUser.ID, cfg.secret)
+	activeUser := databaseUserToUser(user)
+
+	expiresAt := cfg.lifetimes.accessExpiry(expiresIn)
+	accessToken, err := auth.MakeJWT(activeUser.ID, cfg.keys, expiresAt, scopes)
 	if err != nil {
 		respondWithError(
 			resp,
@@ -166,9 +273,14 @@ func (cfg *apiConfig) handleLogin(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	_, err = cfg.db.CreateRefreshToken(
+	savedRefresh, err := cfg.db.CreateRefreshToken(
 		req.Context(),
-		database.CreateRefreshTokenParams{Token: refreshToken, UserID: activeUser.ID},
+		database.CreateRefreshTokenParams{
+			TokenHash:       auth.HashRefreshToken(refreshToken),
+			UserID:          activeUser.ID,
+			LifetimeSeconds: cfg.lifetimes.refresh.Seconds(),
+			Scopes:          auth.ScopeStrings(scopes),
+		},
 	)
 	if err != nil {
 		respondWithError(
@@ -184,7 +296,14 @@ func (cfg *apiConfig) handleLogin(resp http.ResponseWriter, req *http.Request) {
 	respondWithJSON(
 		resp,
 		http.StatusOK,
-		response{User: activeUser, AccessToken: accessToken, RefreshToken: refreshToken},
+		response{
+			User:                  activeUser,
+			AccessToken:           accessToken,
+			ExpiresAt:             expiresAt,
+			RefreshToken:          refreshToken,
+			RefreshTokenExpiresAt: savedRefresh.ExpiresAt,
+			Scopes:                scopes,
+		},
 	)
 }
 
@@ -194,53 +313,86 @@ func (cfg *apiConfig) handleUpdateUser(resp http.ResponseWriter, req *http.Reque
 		User
 	}
 
-	authToken, err := auth.GetBearerToken(req.Header)
+	userID := requiredIdentity(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := Credentials{}
+	err := decoder.Decode(&params)
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusUnauthorized,
-			"Invalid credentials",
-			err,
+			http.StatusInternalServerError,
+			"Couldn't decode parameters",
+			fmt.Errorf("error decoding parameters: %s", err),
 		)
+
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, cfg.secret)
-	if err != nil {
+	if params.Email == "" || params.Password == "" {
 		respondWithError(
 			resp,
-			http.StatusUnauthorized,
-			"Invalid credentials",
-			err,
+			http.StatusForbidden,
+			"Missing required parameter(s): email and password",
+			fmt.Errorf("required parameter(s) missing: %s", err),
 		)
+
 		return
 	}
 
-	decoder := json.NewDecoder(req.Body)
-	params := Credentials{}
-	err = decoder.Decode(&params)
+	addr, err := email.ValidateAddress(params.Email)
 	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusInternalServerError,
-			"Couldn't decode parameters",
-			fmt.Errorf("error decoding parameters: %s", err),
+			http.StatusBadRequest,
+			"Invalid email address",
+			err,
 		)
 
 		return
 	}
 
-	if params.Email == "" || params.Password == "" {
+	currentUser, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
 		respondWithError(
 			resp,
-			http.StatusForbidden,
-			"Missing required parameter(s): email and password",
-			fmt.Errorf("required parameter(s) missing: %s", err),
+			http.StatusInternalServerError,
+			"Unable to update user info",
+			fmt.Errorf("error in retrieving user %s: %s", userID, err),
 		)
 
 		return
 	}
 
+	// a new address only replaces the current one once it's verified;
+	// sending the current address cancels any pending change
+	pendingEmail := sql.NullString{}
+	if addr != currentUser.Email {
+		_, err := cfg.db.GetUserByEmail(req.Context(), addr)
+		if err == nil {
+			respondWithError(
+				resp,
+				http.StatusConflict,
+				"Email already in use",
+				nil,
+			)
+
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(
+				resp,
+				http.StatusInternalServerError,
+				"Unable to update user info",
+				fmt.Errorf("error in checking email availability: %s", err),
+			)
+
+			return
+		}
+
+		pendingEmail = sql.NullString{String: addr, Valid: true}
+	}
+
 	hash, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(
@@ -254,7 +406,7 @@ func (cfg *apiConfig) handleUpdateUser(resp http.ResponseWriter, req *http.Reque
 	}
 
 	updateParams := database.UpdateUserInfoParams{
-		Email:          params.Email,
+		PendingEmail:   pendingEmail,
 		HashedPassword: hash,
 		ID:             userID,
 	}
@@ -271,14 +423,20 @@ func (cfg *apiConfig) handleUpdateUser(resp http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	updatedUser := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+	if pendingEmail.Valid {
+		err := cfg.sendEmailVerification(req.Context(), user.ID, pendingEmail.String)
+		if err != nil {
+			respondWithError(
+				resp,
+				http.StatusInternalServerError,
+				"Unable to send verification email",
+				fmt.Errorf("error in sending verification for %s: %s", user.ID, err),
+			)
+
+			return
+		}
 	}
 
-	respondWithJSON(resp, http.StatusOK, response{User: updatedUser})
+	respondWithJSON(resp, http.StatusOK, response{User: databaseUserToUser(user)})
 
 }
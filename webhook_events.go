@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamsma/webserver/internal/database"
+	"github.com/adamsma/webserver/internal/pagination"
+	"github.com/adamsma/webserver/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// outcomes recorded against a webhook event
+const (
+	webhookPending   = "pending"
+	webhookProcessed = "processed"
+	webhookIgnored   = "ignored"
+	webhookFailed    = "failed"
+)
+
+// how long an attempt at an event may stay pending before a retry takes it
+// over, long enough that a live attempt is never run twice
+const webhookEventLease = 5 * time.Minute
+
+type WebhookEvent struct {
+	ID          string          `json:"id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Outcome     string          `json:"outcome"`
+	Detail      string          `json:"detail,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty"`
+}
+
+func databaseWebhookEventToWebhookEvent(event database.WebhookEvent) WebhookEvent {
+
+	converted := WebhookEvent{
+		ID:         event.ID,
+		EventType:  event.EventType,
+		Payload:    event.Payload,
+		ReceivedAt: event.ReceivedAt,
+		Outcome:    event.Outcome,
+		Detail:     event.Detail,
+		Attempts:   event.Attempts,
+	}
+	if event.ProcessedAt.Valid {
+		converted.ProcessedAt = &event.ProcessedAt.Time
+	}
+
+	return converted
+}
+
+// processPolkaEvent applies an event and records how it went, returning the
+// status to report back along with any error. A replay leaves out the
+// outbound webhooks the event triggered the first time round.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, event polkaEvent, replay bool) (int, error) {
+
+	outcome, status, err := cfg.applyPolkaEvent(ctx, event, replay)
+
+	detail := ""
+	if err != nil {
+		detail = err.Error()
+	}
+
+	// the event has taken effect even if the caller has gone away, so the
+	// outcome is recorded regardless
+	recordErr := cfg.db.CompleteWebhookEvent(
+		context.WithoutCancel(ctx),
+		database.CompleteWebhookEventParams{ID: event.ID, Outcome: outcome, Detail: detail},
+	)
+	if recordErr != nil {
+		// the event has already taken effect, so this isn't worth failing
+		// the delivery over
+		log.Printf("error in recording outcome of webhook event %s: %s", event.ID, recordErr)
+	}
+
+	return status, err
+}
+
+func (cfg *apiConfig) applyPolkaEvent(ctx context.Context, event polkaEvent, replay bool) (string, int, error) {
+
+	var err error
+	switch event.Event {
+	case "user.upgraded", "subscription.renewed":
+		var sub database.UpsertSubscriptionRow
+		sub, err = cfg.db.UpsertSubscription(ctx, event.subscriptionTerms(time.Now()))
+		if err == nil && event.Event == "user.upgraded" && !replay {
+			cfg.emitWebhookEvent(ctx, webhooks.EventUserUpgraded, struct {
+				UserID uuid.UUID `json:"user_id"`
+				Subscription
+			}{UserID: event.Data.UserID, Subscription: databaseSubscriptionToSubscription(database.GetSubscriptionRow(sub))})
+		}
+	case "subscription.payment_failed":
+		// Polka retries the payment; access continues until the period ends
+		_, err = cfg.db.MarkSubscriptionPastDue(ctx, event.Data.UserID)
+	case "subscription.canceled":
+		_, err = cfg.db.CancelSubscription(
+			ctx,
+			database.CancelSubscriptionParams{UserID: event.Data.UserID, Immediately: false},
+		)
+	case "user.downgraded":
+		_, err = cfg.db.CancelSubscription(
+			ctx,
+			database.CancelSubscriptionParams{UserID: event.Data.UserID, Immediately: true},
+		)
+	default:
+		return webhookIgnored, http.StatusNoContent, nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhookFailed, http.StatusNotFound, fmt.Errorf(
+			"no user or subscription for %s", event.Data.UserID,
+		)
+	}
+	if err != nil {
+		return webhookFailed, http.StatusInternalServerError, fmt.Errorf(
+			"error in updating subscription: %s", err,
+		)
+	}
+
+	return webhookProcessed, http.StatusNoContent, nil
+}
+
+// handleAdminListWebhookEvents lists stored events newest first. Filter with
+// outcome, and follow the next Link header for older events.
+func (cfg *apiConfig) handleAdminListWebhookEvents(resp http.ResponseWriter, req *http.Request) {
+
+	query := req.URL.Query()
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	cursor, err := adminListCursor(query.Get("cursor"))
+	if err != nil {
+		respondWithError(resp, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+
+	outcome := sql.NullString{}
+	switch raw := query.Get("outcome"); raw {
+	case "":
+	case webhookPending, webhookProcessed, webhookIgnored, webhookFailed:
+		outcome = sql.NullString{String: raw, Valid: true}
+	default:
+		respondWithError(resp, http.StatusBadRequest, "Invalid outcome", nil)
+		return
+	}
+
+	events, err := cfg.db.ListWebhookEvents(
+		req.Context(),
+		database.ListWebhookEventsParams{
+			CursorReceivedAt: cursor.CreatedAt,
+			CursorID:         cursor.Key,
+			Outcome:          outcome,
+			PageLimit:        int32(limit + 1),
+		},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve webhook events",
+			fmt.Errorf("error in listing webhook events: %s", err),
+		)
+		return
+	}
+
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[len(events)-1]
+		next := pagination.Cursor{
+			CreatedAt: last.ReceivedAt,
+			Key:       last.ID,
+			Direction: pagination.DirectionNext,
+		}
+		resp.Header().Set("Link", pageLink(req, next, "next"))
+	}
+
+	returnEvents := []WebhookEvent{}
+	for _, event := range events {
+		returnEvents = append(returnEvents, databaseWebhookEventToWebhookEvent(event))
+	}
+
+	respondWithJSON(resp, http.StatusOK, returnEvents)
+
+}
+
+// adminListCursor decodes the cursor of an admin listing, which only pages
+// forwards. With none it starts ahead of every stored row.
+func adminListCursor(raw string) (pagination.Cursor, error) {
+
+	if raw == "" {
+		return pagination.Cursor{
+			CreatedAt: endOfTime,
+			ID:        uuid.Max,
+			Direction: pagination.DirectionNext,
+		}, nil
+	}
+
+	cursor, err := pagination.DecodeCursor(raw)
+	if err != nil {
+		return pagination.Cursor{}, err
+	}
+	if cursor.Direction != pagination.DirectionNext {
+		return pagination.Cursor{}, errors.New("admin listings only page forwards")
+	}
+
+	return cursor, nil
+}
+
+// handleAdminReplayWebhookEvent runs a stored event through the current
+// handling code again, whatever its earlier outcome. Subscribers have already
+// been told about the event, so it isn't sent on to them again.
+func (cfg *apiConfig) handleAdminReplayWebhookEvent(resp http.ResponseWriter, req *http.Request) {
+
+	eventID := req.PathValue("eventID")
+
+	// claim the event as a Polka retry would, so a replay can't run
+	// alongside another attempt at it
+	claimed, err := cfg.db.ClaimWebhookEventReplay(
+		req.Context(),
+		database.ClaimWebhookEventReplayParams{
+			ID:           eventID,
+			LeaseSeconds: webhookEventLease.Seconds(),
+		},
+	)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to replay webhook event",
+			fmt.Errorf("error in claiming webhook event %s: %s", eventID, err),
+		)
+		return
+	}
+
+	stored, err := cfg.db.GetWebhookEvent(req.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(resp, http.StatusNotFound, "Webhook event not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to replay webhook event",
+			fmt.Errorf("error in retrieving webhook event %s: %s", eventID, err),
+		)
+		return
+	}
+
+	if claimed == 0 {
+		respondWithError(
+			resp,
+			http.StatusConflict,
+			"Event is already being processed",
+			nil,
+		)
+		return
+	}
+
+	event := polkaEvent{}
+	if err := json.Unmarshal(stored.Payload, &event); err != nil {
+		// give up the claim rather than leave the event pending
+		recordErr := cfg.db.CompleteWebhookEvent(
+			context.WithoutCancel(req.Context()),
+			database.CompleteWebhookEventParams{ID: eventID, Outcome: webhookFailed, Detail: err.Error()},
+		)
+		if recordErr != nil {
+			log.Printf("error in recording outcome of webhook event %s: %s", eventID, recordErr)
+		}
+
+		respondWithError(
+			resp,
+			http.StatusUnprocessableEntity,
+			"Stored payload can't be decoded",
+			err,
+		)
+		return
+	}
+	// keep the stored ID, which may have been assigned on receipt
+	event.ID = stored.ID
+
+	// the replay's own outcome is recorded on the event and returned below,
+	// so a failure here isn't a failure of the request
+	cfg.processPolkaEvent(req.Context(), event, true)
+
+	replayed, err := cfg.db.GetWebhookEvent(req.Context(), eventID)
+	if err != nil {
+		respondWithError(
+			resp,
+			http.StatusInternalServerError,
+			"Unable to retrieve webhook event",
+			fmt.Errorf("error in retrieving webhook event %s: %s", eventID, err),
+		)
+		return
+	}
+
+	respondWithJSON(resp, http.StatusOK, databaseWebhookEventToWebhookEvent(replayed))
+
+}